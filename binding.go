@@ -0,0 +1,227 @@
+package bilibili_http
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// 常用的MIME类型
+const (
+	MIMEJSON              = "application/json"
+	MIMEXML               = "application/xml"
+	MIMEXML2              = "text/xml"
+	MIMEPOSTForm          = "application/x-www-form-urlencoded"
+	MIMEMultipartPOSTForm = "multipart/form-data"
+	MIMEYAML              = "application/x-yaml"
+	MIMEYAML2             = "application/yaml"
+	MIMEMsgpack           = "application/x-msgpack"
+	MIMEMsgpack2          = "application/msgpack"
+)
+
+// defaultMultipartMemory 解析multipart表单时最多放在内存中的字节数，超过的部分会写到临时文件中
+const defaultMultipartMemory = 32 << 20
+
+// ErrUnsupportedContentType 找不到Content-Type对应的解析器
+var ErrUnsupportedContentType = errors.New("web: 不支持的Content-Type")
+
+// Binder 请求数据解析器
+// 一种Content-Type对应一个Binder，想要支持新的格式，实现这个接口然后通过WithBinder注册即可
+type Binder interface {
+	// Name 解析器的名字
+	Name() string
+	// Bind 将请求中的数据解析到dest中
+	Bind(r *http.Request, dest any) error
+}
+
+// JSONBinder 解析JSON格式的请求体
+type JSONBinder struct {
+	// Strict 请求体中出现dest没有的字段时直接报错
+	Strict bool
+	// UseNumber 数字解析成json.Number而不是float64
+	UseNumber bool
+}
+
+func (JSONBinder) Name() string {
+	return "json"
+}
+
+func (b JSONBinder) Bind(r *http.Request, dest any) error {
+	if r.Body == nil {
+		return errors.New("web: 请求体为空")
+	}
+	decoder := json.NewDecoder(r.Body)
+	if b.Strict {
+		decoder.DisallowUnknownFields()
+	}
+	if b.UseNumber {
+		decoder.UseNumber()
+	}
+	return decoder.Decode(dest)
+}
+
+// XMLBinder 解析XML格式的请求体
+type XMLBinder struct{}
+
+func (XMLBinder) Name() string {
+	return "xml"
+}
+
+func (XMLBinder) Bind(r *http.Request, dest any) error {
+	if r.Body == nil {
+		return errors.New("web: 请求体为空")
+	}
+	return xml.NewDecoder(r.Body).Decode(dest)
+}
+
+// FormBinder 解析urlencoded编码的表单以及查询参数
+// 字段通过 form tag 指定对应的key
+type FormBinder struct {
+	// Strict 表单中出现dest没有的字段时直接报错
+	Strict bool
+}
+
+func (FormBinder) Name() string {
+	return "form"
+}
+
+func (b FormBinder) Bind(r *http.Request, dest any) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	return mapForm(dest, r.Form, nil, "form", b.Strict, false)
+}
+
+// MultipartBinder 解析multipart/form-data编码的表单
+// 文件字段可以声明成 *multipart.FileHeader 或者 []*multipart.FileHeader
+type MultipartBinder struct {
+	// MaxMemory 最多放在内存中的字节数，为0时使用默认的32MB
+	MaxMemory int64
+	// Strict 表单中出现dest没有的字段时直接报错
+	Strict bool
+}
+
+func (MultipartBinder) Name() string {
+	return "multipart"
+}
+
+func (b MultipartBinder) Bind(r *http.Request, dest any) error {
+	maxMemory := b.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = defaultMultipartMemory
+	}
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		return err
+	}
+	return mapForm(dest, r.MultipartForm.Value, r.MultipartForm.File, "form", b.Strict, false)
+}
+
+// YAMLBinder 解析YAML格式的请求体
+type YAMLBinder struct {
+	// Strict 请求体中出现dest没有的字段时直接报错
+	Strict bool
+}
+
+func (YAMLBinder) Name() string {
+	return "yaml"
+}
+
+func (b YAMLBinder) Bind(r *http.Request, dest any) error {
+	if r.Body == nil {
+		return errors.New("web: 请求体为空")
+	}
+	decoder := yaml.NewDecoder(r.Body)
+	decoder.KnownFields(b.Strict)
+	return decoder.Decode(dest)
+}
+
+// MsgpackBinder 解析MessagePack格式的请求体
+// 字段名和JSON保持一致，使用 json tag
+type MsgpackBinder struct {
+	// Strict 请求体中出现dest没有的字段时直接报错
+	Strict bool
+}
+
+func (MsgpackBinder) Name() string {
+	return "msgpack"
+}
+
+func (b MsgpackBinder) Bind(r *http.Request, dest any) error {
+	if r.Body == nil {
+		return errors.New("web: 请求体为空")
+	}
+	decoder := msgpack.NewDecoder(r.Body)
+	decoder.SetCustomStructTag("json")
+	decoder.DisallowUnknownFields(b.Strict)
+	return decoder.Decode(dest)
+}
+
+// defaultBinder 根据Content-Type选择内置的解析器
+func defaultBinder(contentType string, strict bool) (Binder, bool) {
+	switch contentType {
+	case MIMEJSON:
+		return JSONBinder{Strict: strict}, true
+	case MIMEXML, MIMEXML2:
+		return XMLBinder{}, true
+	case MIMEPOSTForm:
+		return FormBinder{Strict: strict}, true
+	case MIMEMultipartPOSTForm:
+		return MultipartBinder{Strict: strict}, true
+	case MIMEYAML, MIMEYAML2:
+		return YAMLBinder{Strict: strict}, true
+	case MIMEMsgpack, MIMEMsgpack2:
+		return MsgpackBinder{Strict: strict}, true
+	}
+	return nil, false
+}
+
+// parseContentType 只保留Content-Type中的媒体类型部分
+// application/json; charset=utf-8 => application/json
+func parseContentType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// 格式不规范的情况下，尽量截取分号前面的部分
+		mediaType = strings.TrimSpace(strings.Split(contentType, ";")[0])
+	}
+	return strings.ToLower(mediaType)
+}
+
+// WithBinder 注册或者替换Content-Type对应的解析器
+func WithBinder(contentType string, b Binder) HTTPOption {
+	return func(h *HTTPServer) {
+		if h.binders == nil {
+			h.binders = make(map[string]Binder)
+		}
+		h.binders[parseContentType(contentType)] = b
+	}
+}
+
+// WithStrictBinding 内置解析器是否使用严格模式
+// 严格模式下，请求中出现了结构体没有声明的字段会直接报错
+func WithStrictBinding(strict bool) HTTPOption {
+	return func(h *HTTPServer) {
+		h.strictBinding = strict
+	}
+}
+
+// binder 获取Content-Type对应的解析器
+// 优先使用用户注册的解析器，其次才是内置的解析器
+func (h *HTTPServer) binder(contentType string) (Binder, error) {
+	if b, ok := h.binders[contentType]; ok {
+		return b, nil
+	}
+	if b, ok := defaultBinder(contentType, h.strictBinding); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentType, contentType)
+}
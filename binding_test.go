@@ -0,0 +1,200 @@
+package bilibili_http
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type bindUser struct {
+	Username string    `json:"username" xml:"username" yaml:"username" form:"username"`
+	Age      int       `json:"age" xml:"age" yaml:"age" form:"age"`
+	Tags     []string  `json:"tags" xml:"tags" yaml:"tags" form:"tags"`
+	Birthday time.Time `json:"-" xml:"-" yaml:"-" form:"birthday" time_format:"2006-01-02"`
+}
+
+// TestContextShouldBind 测试根据Content-Type选择解析器
+func TestContextShouldBind(t *testing.T) {
+	msgpackBody, err := msgpack.Marshal(map[string]any{"username": "tom", "age": 18, "tags": []string{"a", "b"}})
+	require.NoError(t, err)
+	testCases := []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+		body        string
+		strict      bool
+
+		wantUser bindUser
+		wantErr  bool
+	}{
+		{
+			name:        "json",
+			method:      http.MethodPost,
+			url:         "/user",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"username":"tom","age":18,"tags":["a","b"],"unknown":1}`,
+			wantUser:    bindUser{Username: "tom", Age: 18, Tags: []string{"a", "b"}},
+		},
+		{
+			name:        "json strict",
+			method:      http.MethodPost,
+			url:         "/user",
+			contentType: MIMEJSON,
+			body:        `{"username":"tom","unknown":1}`,
+			strict:      true,
+			wantErr:     true,
+		},
+		{
+			name:        "xml",
+			method:      http.MethodPost,
+			url:         "/user",
+			contentType: MIMEXML2,
+			body:        `<user><username>tom</username><age>18</age><tags>a</tags><tags>b</tags></user>`,
+			wantUser:    bindUser{Username: "tom", Age: 18, Tags: []string{"a", "b"}},
+		},
+		{
+			name:        "urlencoded",
+			method:      http.MethodPost,
+			url:         "/user",
+			contentType: MIMEPOSTForm,
+			body:        "username=tom&age=18&tags=a&tags=b&birthday=2000-01-02",
+			wantUser: bindUser{Username: "tom", Age: 18, Tags: []string{"a", "b"},
+				Birthday: time.Date(2000, 1, 2, 0, 0, 0, 0, time.Local)},
+		},
+		{
+			name:        "urlencoded strict",
+			method:      http.MethodPost,
+			url:         "/user",
+			contentType: MIMEPOSTForm,
+			body:        "username=tom&unknown=1",
+			strict:      true,
+			wantErr:     true,
+		},
+		{
+			name:        "urlencoded bad int",
+			method:      http.MethodPost,
+			url:         "/user",
+			contentType: MIMEPOSTForm,
+			body:        "age=abc",
+			wantErr:     true,
+		},
+		{
+			name:     "query",
+			method:   http.MethodGet,
+			url:      "/user?username=tom&age=18",
+			wantUser: bindUser{Username: "tom", Age: 18},
+		},
+		{
+			name:        "yaml",
+			method:      http.MethodPost,
+			url:         "/user",
+			contentType: MIMEYAML,
+			body:        "username: tom\nage: 18\ntags: [a, b]\n",
+			wantUser:    bindUser{Username: "tom", Age: 18, Tags: []string{"a", "b"}},
+		},
+		{
+			name:        "yaml strict",
+			method:      http.MethodPost,
+			url:         "/user",
+			contentType: MIMEYAML2,
+			body:        "username: tom\nunknown: 1\n",
+			strict:      true,
+			wantErr:     true,
+		},
+		{
+			name:        "msgpack",
+			method:      http.MethodPost,
+			url:         "/user",
+			contentType: MIMEMsgpack,
+			body:        string(msgpackBody),
+			wantUser:    bindUser{Username: "tom", Age: 18, Tags: []string{"a", "b"}},
+		},
+		{
+			name:        "unsupported",
+			method:      http.MethodPost,
+			url:         "/user",
+			contentType: "application/unknown",
+			body:        "xxx",
+			wantErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			ctx := NewContext(httptest.NewRecorder(), req)
+			ctx.engine = NewHTTP(WithStrictBinding(tc.strict))
+			var user bindUser
+			err := ctx.ShouldBind(&user)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantUser, user)
+		})
+	}
+}
+
+// TestContextShouldBindMultipart 测试multipart表单的解析，包括文件字段
+func TestContextShouldBindMultipart(t *testing.T) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	require.NoError(t, writer.WriteField("username", "tom"))
+	fw, err := writer.CreateFormFile("avatar", "avatar.png")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("png"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	ctx := NewContext(httptest.NewRecorder(), req)
+
+	var form struct {
+		Username string                `form:"username"`
+		Avatar   *multipart.FileHeader `form:"avatar"`
+	}
+	require.NoError(t, ctx.ShouldBind(&form))
+	assert.Equal(t, "tom", form.Username)
+	require.NotNil(t, form.Avatar)
+	assert.Equal(t, "avatar.png", form.Avatar.Filename)
+	assert.Equal(t, int64(3), form.Avatar.Size)
+}
+
+type csvBinder struct{}
+
+func (csvBinder) Name() string {
+	return "csv"
+}
+
+func (csvBinder) Bind(r *http.Request, dest any) error {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r.Body); err != nil {
+		return err
+	}
+	*(dest.(*[]string)) = strings.Split(buf.String(), ",")
+	return nil
+}
+
+// TestWithBinder 测试注册自定义的解析器
+func TestWithBinder(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/csv", strings.NewReader("a,b,c"))
+	req.Header.Set("Content-Type", "text/csv")
+	ctx := NewContext(httptest.NewRecorder(), req)
+	ctx.engine = NewHTTP(WithBinder("text/csv", csvBinder{}))
+	var res []string
+	require.NoError(t, ctx.ShouldBind(&res))
+	assert.Equal(t, []string{"a", "b", "c"}, res)
+}
@@ -0,0 +1,132 @@
+package bilibili_http
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+)
+
+// defaultBodyMemoryLimit 请求体默认最多缓存在内存中的字节数
+// 超过这个大小的请求体会写入临时文件
+const defaultBodyMemoryLimit int64 = 4 << 20
+
+// bodyCache 请求体的缓存
+// http.Request.Body 只能读一次，读完之后数据就没了
+// 所以第一次读取的时候把数据保存下来，之后每次需要读取请求体的时候都从缓存中重新构造一个Reader
+type bodyCache struct {
+	// data 小请求体直接保存在内存中
+	data []byte
+	// file 大请求体保存在临时文件中
+	file *os.File
+	// size 请求体的总大小
+	size int64
+}
+
+// newBodyCache 读取完整的请求体
+// 超过limit的部分连同已经读取的数据一起写入临时文件
+func newBodyCache(body io.Reader, limit int64) (*bodyCache, error) {
+	if body == nil {
+		return &bodyCache{}, nil
+	}
+	var buf bytes.Buffer
+	// 多读一个字节，用来判断有没有超过limit
+	n, err := io.CopyN(&buf, body, limit+1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if n <= limit {
+		return &bodyCache{data: buf.Bytes(), size: n}, nil
+	}
+	file, err := os.CreateTemp("", "bilibili-http-body-")
+	if err != nil {
+		return nil, err
+	}
+	cache := &bodyCache{file: file}
+	if _, err = file.Write(buf.Bytes()); err != nil {
+		_ = cache.close()
+		return nil, err
+	}
+	rest, err := io.Copy(file, body)
+	if err != nil {
+		_ = cache.close()
+		return nil, err
+	}
+	cache.size = n + rest
+	return cache, nil
+}
+
+// reader 从头开始读取请求体
+func (b *bodyCache) reader() io.ReadCloser {
+	if b.file != nil {
+		return io.NopCloser(io.NewSectionReader(b.file, 0, b.size))
+	}
+	return io.NopCloser(bytes.NewReader(b.data))
+}
+
+// bytes 获取完整的请求体
+// 如果请求体保存在临时文件中，这里会把整个文件读到内存中
+func (b *bodyCache) bytes() ([]byte, error) {
+	if b.file == nil {
+		return b.data, nil
+	}
+	return io.ReadAll(b.reader())
+}
+
+// close 删除临时文件
+func (b *bodyCache) close() error {
+	if b.file == nil {
+		return nil
+	}
+	name := b.file.Name()
+	_ = b.file.Close()
+	b.file = nil
+	return os.Remove(name)
+}
+
+// WithBodyMemoryLimit 设置请求体缓存在内存中的上限，超过的部分写入临时文件
+func WithBodyMemoryLimit(limit int64) HTTPOption {
+	return func(h *HTTPServer) {
+		h.bodyMemoryLimit = limit
+	}
+}
+
+// WithMaxBodySize 设置请求体的大小上限，0表示不限制
+// 超过上限之后读取请求体会返回 ErrRequestTooLarge，Bind和Typed视图函数响应413
+// 没有这个限制的话，超过内存上限的请求体会一直写入临时文件，客户端一个请求就能写满磁盘
+func WithMaxBodySize(size int64) HTTPOption {
+	return func(h *HTTPServer) {
+		h.maxBodySize = size
+	}
+}
+
+// maxBytesReader 通过 http.MaxBytesReader 限制请求体的大小，超过的时候返回 ErrRequestTooLarge
+// http.MaxBytesReader 还会让http.Server在响应之后关闭连接，不再读取剩下的数据
+type maxBytesReader struct {
+	io.ReadCloser
+	// remaining 还可以读取的字节数
+	remaining int64
+}
+
+func newMaxBytesReader(w http.ResponseWriter, body io.ReadCloser, size int64) io.ReadCloser {
+	return &maxBytesReader{ReadCloser: http.MaxBytesReader(w, body, size), remaining: size}
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	n, err := m.ReadCloser.Read(p)
+	m.remaining -= int64(n)
+	// 读满了上限之后的错误就是http.MaxBytesReader报告的超过上限
+	if err != nil && err != io.EOF && m.remaining <= 0 {
+		return n, ErrRequestTooLarge
+	}
+	return n, err
+}
+
+// bindStatus 解析请求数据失败时响应的状态码，请求体或者文件过大响应413，其他的响应400
+func bindStatus(err error) int {
+	if errors.Is(err, ErrRequestTooLarge) || errors.Is(err, ErrFileTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
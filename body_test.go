@@ -0,0 +1,166 @@
+package bilibili_http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestContextRawBody 测试请求体可以被多次读取
+func TestContextRawBody(t *testing.T) {
+	testCases := []struct {
+		name  string
+		body  string
+		limit int64
+
+		wantFile bool
+	}{
+		{
+			name: "memory",
+			body: `{"username":"tom"}`,
+		},
+		{
+			name:     "temp file",
+			body:     `{"username":"` + strings.Repeat("a", 64) + `"}`,
+			limit:    16,
+			wantFile: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", MIMEJSON)
+			ctx := NewContext(httptest.NewRecorder(), req)
+			ctx.engine = NewHTTP(WithBodyMemoryLimit(tc.limit))
+
+			data, err := ctx.RawBody()
+			require.NoError(t, err)
+			assert.Equal(t, tc.body, string(data))
+			assert.Equal(t, tc.wantFile, ctx.cacheBody.file != nil)
+
+			for i := 0; i < 2; i++ {
+				var user struct {
+					Username string `json:"username"`
+				}
+				require.NoError(t, ctx.BindJSON(&user))
+				assert.Equal(t, tc.body, `{"username":"`+user.Username+`"}`)
+			}
+
+			var name string
+			if tc.wantFile {
+				name = ctx.cacheBody.file.Name()
+			}
+			ctx.release()
+			if tc.wantFile {
+				_, err = os.Stat(name)
+				assert.True(t, os.IsNotExist(err))
+			}
+		})
+	}
+}
+
+// TestContextFormAfterRawBody 测试中间件读取过请求体之后还能正常获取表单数据
+func TestContextFormAfterRawBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("username=tom&password=123"))
+	req.Header.Set("Content-Type", MIMEPOSTForm)
+	ctx := NewContext(httptest.NewRecorder(), req)
+
+	data, err := ctx.RawBody()
+	require.NoError(t, err)
+	assert.Equal(t, "username=tom&password=123", string(data))
+
+	username, err := ctx.Form("username")
+	require.NoError(t, err)
+	assert.Equal(t, "tom", username)
+}
+
+// TestMaxBodySize 测试请求体超过上限的时候响应413，不会继续写入临时文件
+func TestMaxBodySize(t *testing.T) {
+	type user struct {
+		Username string `json:"username"`
+	}
+	small := `{"username":"tom"}`
+	large := `{"username":"` + strings.Repeat("a", 1024) + `"}`
+	testCases := []struct {
+		name    string
+		opts    []HTTPOption
+		handler HandleFunc
+		body    string
+
+		wantCode int
+	}{
+		{
+			name: "bind",
+			opts: []HTTPOption{WithMaxBodySize(64)},
+			handler: func(ctx *Context) {
+				var u user
+				if ctx.Bind(&u) == nil {
+					ctx.TEXT(http.StatusOK, u.Username)
+				}
+			},
+			body:     small,
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "bind too large",
+			opts: []HTTPOption{WithMaxBodySize(64)},
+			handler: func(ctx *Context) {
+				var u user
+				if ctx.Bind(&u) == nil {
+					ctx.TEXT(http.StatusOK, u.Username)
+				}
+			},
+			body:     large,
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			// 超过内存上限的部分写入临时文件之前就被拦下来了
+			name: "raw body too large",
+			opts: []HTTPOption{WithMaxBodySize(64), WithBodyMemoryLimit(16)},
+			handler: E(func(ctx *Context) error {
+				_, err := ctx.RawBody()
+				assert.ErrorIs(t, err, ErrRequestTooLarge)
+				assert.Nil(t, ctx.cacheBody)
+				return err
+			}),
+			body:     large,
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "typed too large",
+			opts: []HTTPOption{WithMaxBodySize(64)},
+			handler: Typed(func(ctx *Context, req *user) (*user, error) {
+				return req, nil
+			}),
+			body:     large,
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "unlimited",
+			handler: func(ctx *Context) {
+				var u user
+				if ctx.Bind(&u) == nil {
+					ctx.TEXT(http.StatusOK, u.Username)
+				}
+			},
+			body:     large,
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHTTP(append([]HTTPOption{WithLogger(NopLogger())}, tc.opts...)...)
+			h.POST("/user", tc.handler)
+			req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", MIMEJSON)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+		})
+	}
+}
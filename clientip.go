@@ -0,0 +1,103 @@
+package bilibili_http
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// WithTrustedProxies 设置可信的代理，可以是IP或者CIDR，例如 10.0.0.0/8
+// 只有直接连上来的是可信代理时，才会从 X-Forwarded-For 和 X-Real-IP 中获取客户端IP
+// 没有设置的时候完全不信任这两个请求头，因为客户端可以随意伪造
+// IPv4映射的IPv6地址按照IPv4处理，例如 ::ffff:10.0.0.1 等同于 10.0.0.1
+func WithTrustedProxies(proxies ...string) HTTPOption {
+	return func(h *HTTPServer) {
+		h.trustedProxies = make([]netip.Prefix, 0, len(proxies))
+		for _, proxy := range proxies {
+			prefix, err := parseProxy(proxy)
+			if err != nil {
+				panic(fmt.Sprintf("web: 非法的代理地址 %s", proxy))
+			}
+			h.trustedProxies = append(h.trustedProxies, prefix)
+		}
+	}
+}
+
+// parseProxy 单个IP按照地址族转换成 /32 或者 /128
+func parseProxy(proxy string) (netip.Prefix, error) {
+	if !strings.Contains(proxy, "/") {
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.Unmap().WithZone("")
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(proxy)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr, bits := prefix.Addr(), prefix.Bits()
+	if addr.Is4In6() {
+		// 映射的前缀 ::ffff:0:0/96 以内的部分才是IPv4地址
+		if bits < 96 {
+			return netip.Prefix{}, fmt.Errorf("web: 非法的代理地址 %s", proxy)
+		}
+		addr, bits = addr.Unmap(), bits-96
+	}
+	return netip.PrefixFrom(addr, bits).Masked(), nil
+}
+
+// ClientIP 获取客户端的IP
+// 从 X-Forwarded-For 的右边往左找第一个不是可信代理的IP，找不到的话再看 X-Real-IP
+// 都没有的话就是直接连上来的IP
+func (c *Context) ClientIP() string {
+	remoteIP := c.remoteIP()
+	if !c.trustedProxy(remoteIP) {
+		return remoteIP
+	}
+	if forwarded := c.request.Header.Get("X-Forwarded-For"); forwarded != "" {
+		ips := strings.Split(forwarded, ",")
+		for i := len(ips) - 1; i >= 0; i-- {
+			ip := strings.TrimSpace(ips[i])
+			if net.ParseIP(ip) == nil {
+				// 非法的值，后面的都不能信了
+				break
+			}
+			if i == 0 || !c.trustedProxy(ip) {
+				return ip
+			}
+		}
+	}
+	if ip := strings.TrimSpace(c.request.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return remoteIP
+}
+
+// remoteIP 直接连上来的IP
+func (c *Context) remoteIP() string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(c.request.RemoteAddr))
+	if err != nil {
+		return c.request.RemoteAddr
+	}
+	return host
+}
+
+func (c *Context) trustedProxy(ip string) bool {
+	if c.engine == nil || len(c.engine.trustedProxies) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap().WithZone("")
+	for _, prefix := range c.engine.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package bilibili_http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestContextClientIP 测试获取客户端IP，只信任可信代理转发过来的请求头
+func TestContextClientIP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		proxies    []string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		wantIP     string
+	}{
+		{
+			name:       "no proxy",
+			remoteAddr: "1.1.1.1:1234",
+			forwarded:  "2.2.2.2",
+			wantIP:     "1.1.1.1",
+		},
+		{
+			name:       "untrusted proxy",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "1.1.1.1:1234",
+			forwarded:  "2.2.2.2",
+			wantIP:     "1.1.1.1",
+		},
+		{
+			// 客户端伪造的 3.3.3.3 在最左边，不会被采用
+			name:       "trusted proxies",
+			proxies:    []string{"10.0.0.0/8", "192.168.1.1"},
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  "3.3.3.3, 2.2.2.2, 192.168.1.1",
+			wantIP:     "2.2.2.2",
+		},
+		{
+			name:       "invalid forwarded",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  "unknown",
+			realIP:     "2.2.2.2",
+			wantIP:     "2.2.2.2",
+		},
+		{
+			// IPv4映射的IPv6地址只信任它自己，不能变成 ::/32 这样很大的范围
+			name:       "ipv4 mapped proxy",
+			proxies:    []string{"::ffff:10.0.0.1"},
+			remoteAddr: "[::2]:1234",
+			forwarded:  "2.2.2.2",
+			wantIP:     "::2",
+		},
+		{
+			name:       "ipv4 mapped remote",
+			proxies:    []string{"::ffff:10.0.0.1"},
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  "2.2.2.2",
+			wantIP:     "2.2.2.2",
+		},
+		{
+			name:       "ipv4 mapped cidr",
+			proxies:    []string{"::ffff:10.0.0.0/104"},
+			remoteAddr: "[::ffff:10.1.2.3]:1234",
+			forwarded:  "2.2.2.2, 10.0.0.8",
+			wantIP:     "2.2.2.2",
+		},
+		{
+			name:       "ipv6",
+			proxies:    []string{"::1"},
+			remoteAddr: "[::1]:1234",
+			forwarded:  "2001:db8::1",
+			wantIP:     "2001:db8::1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwarded)
+			}
+			if tc.realIP != "" {
+				req.Header.Set("X-Real-IP", tc.realIP)
+			}
+			ctx := NewContext(httptest.NewRecorder(), req)
+			ctx.engine = NewHTTP(WithTrustedProxies(tc.proxies...))
+			assert.Equal(t, tc.wantIP, ctx.ClientIP())
+		})
+	}
+
+	for _, proxy := range []string{"10.0.0.300", "10.0.0.0/33", "::ffff:10.0.0.0/64", "proxy"} {
+		assert.PanicsWithValue(t, "web: 非法的代理地址 "+proxy, func() {
+			NewHTTP(WithTrustedProxies(proxy))
+		})
+	}
+}
@@ -1,19 +1,27 @@
 package bilibili_http
 
 import (
-	"encoding/json"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"sync"
 )
 
 // H 提供一个新类型，方便操作
 type H map[string]any
 
 // Context 上下文
+// 上下文是从池子中取出来复用的，视图函数返回之后就会被下一个请求使用
+// 所以不能在视图函数返回之后继续持有上下文，包括在视图函数中开启的goroutine
+// 需要在goroutine中使用的话，先调用 Copy 复制一份
 type Context struct {
+	// engine 当前请求所属的服务，用来获取服务级别的配置
+	// 直接通过NewContext创建的上下文中为nil
+	engine *HTTPServer
 	// 响应
 	response http.ResponseWriter
 	// 请求
@@ -22,9 +30,18 @@ type Context struct {
 	Method string
 	// 请求URL
 	Pattern string
+	// route 匹配到的路由，例如 /user/:id，没有匹配到路由时为空
+	route string
 	// params 参数路由参数
 	params map[string]string
 
+	// keys 中间件和视图函数之间传递数据的键值对
+	keys map[string]any
+	// mutex 保护keys、errors、released以及request的替换，视图函数中开启的goroutine也可能读到它们
+	mutex sync.RWMutex
+	// released 上下文是否已经放回池子，放回之后当成已经取消的context
+	released bool
+
 	// 请求相关的信息
 	// 1. 请求参数:
 	// GET /user/:id
@@ -41,16 +58,36 @@ type Context struct {
 
 	// cacheQuery 内部维护一份查询查参数数据
 	cacheQuery url.Values
-	// cacheBody 内部维护一份请求体数据
-	cacheBody io.ReadCloser
+	// cacheBody 内部维护一份请求体数据，第一次读取请求体的时候才会创建
+	cacheBody *bodyCache
+	// multipartForm 解析好的multipart表单，请求结束之后需要删除临时文件
+	multipartForm *multipart.Form
 
 	// 下面是响应相关的信息
 	// 1. 状态码
 	status int
 	// 2. 响应头
-	header map[string]string
+	header http.Header
 	// 3. 响应体
 	data []byte
+	// written 是否已经设置过状态码或者响应体
+	written bool
+	// committed 响应是否已经写入到ResponseWriter中
+	committed bool
+	// size 已经写入ResponseWriter的响应体的字节数
+	size int
+	// logger 带上了请求信息的Logger，第一次调用Logger方法时创建
+	logger Logger
+	// loggerRequestID 创建logger时的请求ID
+	loggerRequestID string
+
+	// aborted 是否已经中断了后续的中间件和视图函数
+	aborted bool
+	// errors 处理请求过程中积累的错误
+	errors ErrorList
+
+	// signature 不为nil时表示这是生成接口文档时的探测，Typed视图函数只需要报告自己的类型
+	signature *typedSignature
 }
 
 // Params 获取请求参数
@@ -85,8 +122,8 @@ func (c *Context) Query(key string) (string, error) {
 // 只解析urlencoded编码格式的数据
 // 纯粹获取请求体中的数据
 func (c *Context) Form(key string) (string, error) {
-	if c.cacheBody == nil {
-		c.cacheBody = c.request.Body
+	if err := c.rewindBody(); err != nil {
+		return "", err
 	}
 	// 必须先使用ParseForm方法
 	if err := c.request.ParseForm(); err != nil {
@@ -96,14 +133,229 @@ func (c *Context) Form(key string) (string, error) {
 	return c.request.FormValue(key), nil
 }
 
+// ContentType 获取请求的Content-Type，只保留媒体类型部分
+func (c *Context) ContentType() string {
+	return parseContentType(c.request.Header.Get("Content-Type"))
+}
+
+// ShouldBind 根据请求的Content-Type自动选择解析器解析数据
+// GET、HEAD、DELETE这类一般没有请求体的请求，没有Content-Type时解析查询参数
+func (c *Context) ShouldBind(dest any) error {
+	contentType := c.ContentType()
+	if contentType == "" {
+		switch c.Method {
+		case http.MethodGet, http.MethodHead, http.MethodDelete:
+			return c.ShouldBindWith(dest, FormBinder{Strict: c.strictBinding()})
+		}
+	}
+	b, err := c.binder(contentType)
+	if err != nil {
+		return err
+	}
+	return c.ShouldBindWith(dest, b)
+}
+
+// Bind 和 ShouldBind 一样，只不过解析失败的时候直接中断请求并响应400，请求体过大响应413
+// 错误会以 ErrorTypeBind 类型记录下来，交给统一的错误处理中间件渲染
+func (c *Context) Bind(dest any) error {
+	if err := c.ShouldBind(dest); err != nil {
+		c.AbortWithError(bindStatus(err), err).SetType(ErrorTypeBind)
+		return err
+	}
+	return nil
+}
+
+// ShouldBindWith 使用指定的解析器解析数据
+// multipart表单不会缓存请求体，直接按照上传配置从连接中读取，大小和类型的限制在读取的过程中生效
+func (c *Context) ShouldBindWith(dest any, b Binder) error {
+	if _, ok := b.(MultipartBinder); ok {
+		if _, err := c.MultipartForm(); err != nil {
+			return err
+		}
+		return b.Bind(c.request, dest)
+	}
+	if err := c.rewindBody(); err != nil {
+		return err
+	}
+	return b.Bind(c.request, dest)
+}
+
+// RawBody 获取完整的请求体
+// 请求体只会从连接中读取一次，之后的RawBody、Form以及各种Bind都是从缓存中读取
+func (c *Context) RawBody() ([]byte, error) {
+	if err := c.loadBody(); err != nil {
+		return nil, err
+	}
+	return c.cacheBody.bytes()
+}
+
+// BodyReader 获取一个从头开始读取请求体的Reader
+// 适合请求体比较大，不想一次性读到内存中的场景
+func (c *Context) BodyReader() (io.ReadCloser, error) {
+	if err := c.loadBody(); err != nil {
+		return nil, err
+	}
+	return c.cacheBody.reader(), nil
+}
+
+// loadBody 读取请求体并缓存起来
+func (c *Context) loadBody() error {
+	if c.cacheBody != nil {
+		return nil
+	}
+	limit := defaultBodyMemoryLimit
+	if c.engine != nil && c.engine.bodyMemoryLimit > 0 {
+		limit = c.engine.bodyMemoryLimit
+	}
+	cache, err := newBodyCache(c.request.Body, limit)
+	if err != nil {
+		return err
+	}
+	if c.request.Body != nil {
+		_ = c.request.Body.Close()
+	}
+	c.cacheBody = cache
+	return nil
+}
+
+// rewindBody 让request.Body重新从头开始读
+func (c *Context) rewindBody() error {
+	if err := c.loadBody(); err != nil {
+		return err
+	}
+	c.request.Body = c.cacheBody.reader()
+	return nil
+}
+
+// release 请求处理结束之后释放上下文占用的资源
+func (c *Context) release() {
+	if c.cacheBody != nil {
+		_ = c.cacheBody.close()
+	}
+	if c.multipartForm != nil {
+		_ = c.multipartForm.RemoveAll()
+	}
+	if c.request.MultipartForm != nil {
+		_ = c.request.MultipartForm.RemoveAll()
+	}
+	// 放回池子之前不再引用请求和响应，避免它们迟迟不能被回收
+	c.response = nil
+	c.data = nil
+	c.mutex.Lock()
+	c.request = nil
+	c.keys = nil
+	c.released = true
+	c.mutex.Unlock()
+}
+
+// reset 从池子中取出上下文之后，清空上一个请求留下的数据
+// params 和 header 的map直接复用，其余的字段恢复成零值
+func (c *Context) reset(w http.ResponseWriter, r *http.Request) {
+	c.mutex.Lock()
+	c.request = r
+	c.keys = nil
+	c.errors = nil
+	c.released = false
+	c.mutex.Unlock()
+	c.response = w
+	c.Method = r.Method
+	c.Pattern = r.URL.Path
+	c.route = ""
+	c.logger = nil
+	c.loggerRequestID = ""
+	for key := range c.params {
+		delete(c.params, key)
+	}
+	c.cacheQuery = nil
+	c.cacheBody = nil
+	c.multipartForm = nil
+	c.status = http.StatusOK
+	for key := range c.header {
+		delete(c.header, key)
+	}
+	c.data = nil
+	c.written = false
+	c.committed = false
+	c.size = 0
+	c.aborted = false
+	c.signature = nil
+}
+
+// Copy 复制一份上下文，可以在视图函数返回之后继续使用，例如交给goroutine异步处理
+// 复制出来的上下文只能读取请求的数据，不能用来响应请求
+// 注意：复制出来的上下文的 Done 依然跟随原来的请求，请求结束之后就会被取消
+func (c *Context) Copy() *Context {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	cp := &Context{
+		engine:          c.engine,
+		request:         c.request,
+		Method:          c.Method,
+		Pattern:         c.Pattern,
+		route:           c.route,
+		logger:          c.logger,
+		loggerRequestID: c.loggerRequestID,
+		params:          make(map[string]string, len(c.params)),
+		status:          c.status,
+		header:          c.header.Clone(),
+		data:            c.data,
+		written:         c.written,
+		aborted:         c.aborted,
+		errors:          append(ErrorList(nil), c.errors...),
+		released:        c.released,
+	}
+	for key, value := range c.params {
+		cp.params[key] = value
+	}
+	if c.keys != nil {
+		cp.keys = make(map[string]any, len(c.keys))
+		for key, value := range c.keys {
+			cp.keys[key] = value
+		}
+	}
+	return cp
+}
+
 // BindJSON 解析JSON格式数据的请求
 func (c *Context) BindJSON(dest any) error {
-	if c.cacheQuery == nil {
-		c.cacheBody = c.request.Body
+	b, err := c.binder(MIMEJSON)
+	if err != nil {
+		return err
 	}
-	decoder := json.NewDecoder(c.cacheBody)
-	decoder.DisallowUnknownFields()
-	return decoder.Decode(dest)
+	return c.ShouldBindWith(dest, b)
+}
+
+// BindXML 解析XML格式数据的请求
+func (c *Context) BindXML(dest any) error {
+	b, err := c.binder(MIMEXML)
+	if err != nil {
+		return err
+	}
+	return c.ShouldBindWith(dest, b)
+}
+
+// BindYAML 解析YAML格式数据的请求
+func (c *Context) BindYAML(dest any) error {
+	b, err := c.binder(MIMEYAML)
+	if err != nil {
+		return err
+	}
+	return c.ShouldBindWith(dest, b)
+}
+
+// binder 获取Content-Type对应的解析器
+func (c *Context) binder(contentType string) (Binder, error) {
+	if c.engine != nil {
+		return c.engine.binder(contentType)
+	}
+	if b, ok := defaultBinder(contentType, false); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentType, contentType)
+}
+
+func (c *Context) strictBinding() bool {
+	return c.engine != nil && c.engine.strictBinding
 }
 
 func NewContext(w http.ResponseWriter, r *http.Request) *Context {
@@ -112,28 +364,63 @@ func NewContext(w http.ResponseWriter, r *http.Request) *Context {
 		request:  r,
 		Method:   r.Method,
 		Pattern:  r.URL.Path,
-		header:   map[string]string{},
+		status:   http.StatusOK,
+		header:   http.Header{},
 	}
 }
 
 // SetStatusCode 设置状态码
 func (c *Context) SetStatusCode(code int) {
 	c.status = code
+	c.written = true
 }
 
 // SetHeader 设置响应头
 func (c *Context) SetHeader(key string, value string) {
-	c.header[key] = value
+	c.header.Set(key, value)
 }
 
 // DelHeader 删除响应头
 func (c *Context) DelHeader(key string) {
-	delete(c.header, key)
+	c.header.Del(key)
 }
 
 // SetData 设置响应体
 func (c *Context) SetData(data []byte) {
 	c.data = data
+	c.written = true
+}
+
+// Written 是否已经设置过状态码或者响应体
+// 中间件可以用来判断后面的中间件或者视图函数有没有响应数据
+func (c *Context) Written() bool {
+	return c.written
+}
+
+// Route 获取匹配到的路由，例如 /user/:id
+// 和 Pattern 不同，同一个路由的所有请求得到的都是一样的，适合用在日志和监控中做聚合
+func (c *Context) Route() string {
+	return c.route
+}
+
+// Request 获取原始的请求对象
+func (c *Context) Request() *http.Request {
+	return c.request
+}
+
+// StatusCode 获取当前设置的响应状态码
+func (c *Context) StatusCode() int {
+	return c.status
+}
+
+// ResponseHeader 获取当前设置的响应头，修改返回值会直接影响响应
+func (c *Context) ResponseHeader() http.Header {
+	return c.header
+}
+
+// ResponseData 获取当前设置的响应体
+func (c *Context) ResponseData() []byte {
+	return c.data
 }
 
 // 所以，SetStatusCode、SetHeader、SetData就类似一些小零件，我们需要提供一些成型的方法给到用户使用
@@ -142,48 +429,90 @@ func (c *Context) SetData(data []byte) {
 // 3. 响应纯文本格式
 // ....
 
-// JSON 响应JSON格式数据
-func (c *Context) JSON(code int, data any) {
-	c.SetStatusCode(code)
-	c.SetHeader("Context-Type", "application/json")
-	res, err := json.Marshal(data)
-	//encoder := json.NewEncoder(c.response)
-	//err = encoder.Encode(data)
-	if err != nil {
+// Respond 使用渲染器响应数据
+// 渲染失败的话，和之前一样直接panic，交给recovery兜底
+func (c *Context) Respond(code int, r Render) {
+	var buf bytes.Buffer
+	if err := r.Render(&buf); err != nil {
+		c.Error(err).SetType(ErrorTypeRender)
 		// 现在程序存在的问题：咱们这里是直接panic
 		// 那我们之前设置的状态码和响应头需要去掉吗？
 		// 最好是去掉
 		c.SetStatusCode(http.StatusInternalServerError)
-		c.DelHeader("Context-Type")
+		c.DelHeader("Content-Type")
 		panic(err)
 	}
-	c.SetData(res)
+	c.SetStatusCode(code)
+	c.SetHeader("Content-Type", r.ContentType())
+	c.SetData(buf.Bytes())
+}
+
+// JSON 响应JSON格式数据
+func (c *Context) JSON(code int, data any) {
+	c.Respond(code, JSONRender{Data: data})
+}
+
+// IndentedJSON 响应带缩进的JSON格式数据
+func (c *Context) IndentedJSON(code int, data any) {
+	c.Respond(code, IndentedJSONRender{Data: data})
+}
+
+// JSONP 响应JSONP格式数据，回调函数名从查询参数callback中获取
+// 回调函数名是客户端传过来的，不合法的时候交给 ErrorHandler 响应400
+func (c *Context) JSONP(code int, data any) {
+	callback, _ := c.Query("callback")
+	if callback != "" && !jsonpCallbackRegexp.MatchString(callback) {
+		c.handleError(NewHTTPError(http.StatusBadRequest, "非法的JSONP回调函数名"))
+		return
+	}
+	c.Respond(code, JSONPRender{Callback: callback, Data: data})
+}
+
+// SecureJSON 响应带防劫持前缀的JSON格式数据
+func (c *Context) SecureJSON(code int, data any) {
+	c.Respond(code, SecureJSONRender{Data: data})
+}
+
+// XML 响应XML格式数据
+func (c *Context) XML(code int, data any) {
+	c.Respond(code, XMLRender{Data: data})
+}
+
+// YAML 响应YAML格式数据
+func (c *Context) YAML(code int, data any) {
+	c.Respond(code, YAMLRender{Data: data})
+}
+
+// Msgpack 响应MessagePack格式数据
+func (c *Context) Msgpack(code int, data any) {
+	c.Respond(code, MsgpackRender{Data: data})
 }
 
 // HTML 响应HTML格式数据
 func (c *Context) HTML(code int, html string) {
-	c.SetStatusCode(code)
-	c.SetHeader("Context-Type", "text/html")
-	c.SetData([]byte(html))
+	c.Respond(code, HTMLRender{HTML: html})
 }
 
-// TEXT 响应HTML格式数据
+// TEXT 响应纯文本格式数据
 func (c *Context) TEXT(code int, text string) {
-	c.SetStatusCode(code)
-	c.SetHeader("Context-Type", "text/plain")
-	c.SetData([]byte(text))
+	c.Respond(code, TextRender{Text: text})
 }
 
-// 将数据全部写入响应中
-func (c *Context) flashDataToResponse() {
-	// 写入状态码
-	c.response.WriteHeader(c.status)
-	// 写入响应头
-	for key, value := range c.header {
-		c.response.Header().Set(key, value)
+// Negotiate 内容协商：根据请求的Accept从offers中选出最合适的格式响应
+// 权重相同的情况下优先选择靠前的offer，一个都不满足的时候响应406
+// c.Negotiate(http.StatusOK, JSONRender{Data: data}, XMLRender{Data: data})
+func (c *Context) Negotiate(code int, offers ...Render) {
+	contentTypes := make([]string, 0, len(offers))
+	for _, offer := range offers {
+		contentTypes = append(contentTypes, offer.ContentType())
+	}
+	c.SetHeader("Vary", "Accept")
+	idx := negotiate(c.request.Header.Get("Accept"), contentTypes)
+	if idx < 0 {
+		c.TEXT(http.StatusNotAcceptable, http.StatusText(http.StatusNotAcceptable))
+		return
 	}
-	// 写入响应体
-	c.response.Write(c.data)
+	c.Respond(code, offers[idx])
 }
 
 // 注意：
@@ -0,0 +1,111 @@
+package bilibili_http
+
+import (
+	"context"
+	"time"
+)
+
+// Context 实现了 context.Context 接口，可以直接传给数据库驱动、RPC客户端等需要context的地方
+// Deadline、Done、Err 都是委托给请求的context，客户端断开连接的时候Done会被关闭
+// Value 优先从上下文的键值对中查找，找不到再到请求的context中查找
+// 上下文会被下一个请求复用，交给视图函数返回之后还在运行的goroutine时，传 ctx.Copy() 而不是ctx本身
+var _ context.Context = &Context{}
+
+// releasedContext 上下文放回池子之后就没有请求了，当成已经取消的context
+// 视图函数返回之后还在goroutine中使用上下文的时候，Done立即返回而不是空指针panic
+var releasedContext = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+// stdContext 请求的context，上下文已经释放的时候返回 releasedContext
+func (c *Context) stdContext() context.Context {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.parentContext()
+}
+
+// parentContext 和 stdContext 一样，调用方需要持有锁
+func (c *Context) parentContext() context.Context {
+	if c.released || c.request == nil {
+		return releasedContext
+	}
+	return c.request.Context()
+}
+
+// deriveContext 在请求的context上派生出新的context并替换掉原来的，读取、派生和替换都在锁里完成
+// 上下文已经释放的时候从 releasedContext 派生，得到的是已经取消的context，不会替换
+func (c *Context) deriveContext(derive func(parent context.Context) context.Context) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ctx := derive(c.parentContext())
+	if c.released || c.request == nil {
+		return
+	}
+	c.request = c.request.WithContext(ctx)
+}
+
+// Deadline 请求的截止时间
+func (c *Context) Deadline() (time.Time, bool) {
+	return c.stdContext().Deadline()
+}
+
+// Done 请求被取消或者超时的时候关闭
+func (c *Context) Done() <-chan struct{} {
+	return c.stdContext().Done()
+}
+
+// Err 请求被取消或者超时的原因
+func (c *Context) Err() error {
+	return c.stdContext().Err()
+}
+
+// Value 获取key对应的值
+// key是string类型的时候先从 Set 保存的键值对中查找
+func (c *Context) Value(key any) any {
+	if k, ok := key.(string); ok {
+		if value, ok := c.Get(k); ok {
+			return value
+		}
+	}
+	return c.stdContext().Value(key)
+}
+
+// WithContext 替换请求的context，之后的Deadline、Done、Err、Value都以新的context为准
+// 一般用于中间件在请求的context上派生出新的context，上下文已经释放的时候什么都不做
+func (c *Context) WithContext(ctx context.Context) {
+	c.deriveContext(func(context.Context) context.Context {
+		return ctx
+	})
+}
+
+// WithTimeout 给请求设置超时时间，调用方需要在请求结束之后调用返回的cancel
+func (c *Context) WithTimeout(timeout time.Duration) context.CancelFunc {
+	var cancel context.CancelFunc
+	c.deriveContext(func(parent context.Context) context.Context {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(parent, timeout)
+		return ctx
+	})
+	return cancel
+}
+
+// WithDeadline 给请求设置截止时间，调用方需要在请求结束之后调用返回的cancel
+func (c *Context) WithDeadline(deadline time.Time) context.CancelFunc {
+	var cancel context.CancelFunc
+	c.deriveContext(func(parent context.Context) context.Context {
+		var ctx context.Context
+		ctx, cancel = context.WithDeadline(parent, deadline)
+		return ctx
+	})
+	return cancel
+}
+
+// WithValue 在请求的context中保存数据，适合需要传给下游库的数据
+// 只在框架内部使用的数据用 Set 就够了
+func (c *Context) WithValue(key any, value any) {
+	c.deriveContext(func(parent context.Context) context.Context {
+		return context.WithValue(parent, key, value)
+	})
+}
@@ -0,0 +1,130 @@
+package bilibili_http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxKey struct{}
+
+// TestContextStd 测试Context作为context.Context使用
+func TestContextStd(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(parent)
+	ctx := NewContext(httptest.NewRecorder(), req)
+
+	ctx.Set("user", "tom")
+	ctx.WithValue(ctxKey{}, "trace")
+	assert.Equal(t, "tom", ctx.Value("user"))
+	assert.Equal(t, "trace", ctx.Value(ctxKey{}))
+	assert.Nil(t, ctx.Value("missing"))
+
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+	stop := ctx.WithTimeout(time.Minute)
+	defer stop()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	// 模拟客户端断开连接
+	cancel()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("客户端断开之后Done没有关闭")
+	}
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	// 派生出来的context依然能拿到之前的值
+	assert.Equal(t, "trace", ctx.Value(ctxKey{}))
+}
+
+// TestContextStdReleased 测试上下文放回池子之后依然可以安全地当成context使用
+func TestContextStdReleased(t *testing.T) {
+	h := NewHTTP()
+	var leaked *Context
+	h.GET("/user", func(ctx *Context) {
+		ctx.Set("user", "tom")
+		leaked = ctx
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user", nil))
+
+	select {
+	case <-leaked.Done():
+	default:
+		t.Fatal("释放之后Done没有关闭")
+	}
+	assert.ErrorIs(t, leaked.Err(), context.Canceled)
+	_, ok := leaked.Deadline()
+	assert.False(t, ok)
+	assert.Nil(t, leaked.Value("user"))
+	assert.Nil(t, leaked.Value(ctxKey{}))
+
+	// 释放之后派生出来的都是已经取消的context，也不会替换掉请求的context
+	assert.NotPanics(t, func() {
+		stop := leaked.WithTimeout(time.Minute)
+		defer stop()
+		stop = leaked.WithDeadline(time.Now().Add(time.Minute))
+		defer stop()
+		leaked.WithValue(ctxKey{}, "trace")
+		leaked.WithContext(context.Background())
+	})
+	_, ok = leaked.Deadline()
+	assert.False(t, ok)
+	assert.ErrorIs(t, leaked.Err(), context.Canceled)
+	assert.Nil(t, leaked.Value(ctxKey{}))
+}
+
+// TestContextStdReuse 测试goroutine持有的上下文被下一个请求复用的时候不会出现数据竞争
+// 需要在goroutine中继续使用的应该传 ctx.Copy()，复制出来的上下文不受复用的影响
+// go test -race -run TestContextStdReuse
+func TestContextStdReuse(t *testing.T) {
+	h := NewHTTP()
+	leaked := make(chan *Context, 1)
+	copied := make(chan *Context, 1)
+	h.GET("/user", func(ctx *Context) {
+		ctx.Set("user", ctx.Request().URL.Query().Get("name"))
+		ctx.Error(errors.New("db down"))
+		select {
+		case leaked <- ctx:
+			copied <- ctx.Copy()
+		default:
+		}
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user?name=tom", nil))
+	ctx, cp := <-leaked, <-copied
+
+	started, stop, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		close(started)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			_ = ctx.Done()
+			_ = ctx.Err()
+			_ = ctx.Value("user")
+			ctx.WithValue(ctxKey{}, "trace")
+			ctx.WithTimeout(time.Minute)()
+		}
+	}()
+	<-started
+	for i := 0; i < 1000; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user?name=jerry", nil))
+	}
+	close(stop)
+	<-done
+
+	assert.Equal(t, "tom", cp.Value("user"))
+	assert.Len(t, cp.Errors(), 1)
+	assert.NoError(t, cp.Err())
+}
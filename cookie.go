@@ -0,0 +1,277 @@
+package bilibili_http
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidCookie Cookie被篡改，或者签名的密钥已经不在密钥列表中了
+	ErrInvalidCookie = errors.New("web: 非法的Cookie")
+	// ErrNoCookieKeys 没有通过 WithCookieKeys 配置密钥
+	ErrNoCookieKeys = errors.New("web: 没有配置Cookie密钥")
+	// ErrInvalidCookieName Cookie的名字中有空格、分号之类不允许出现的字符
+	ErrInvalidCookieName = errors.New("web: 非法的Cookie名字")
+)
+
+// CookieOptions 设置Cookie时的属性
+type CookieOptions struct {
+	// Path 为空时默认是 /
+	Path   string
+	Domain string
+	// MaxAge 大于0表示多少秒后过期，小于0表示立即删除，等于0表示不设置
+	MaxAge int
+	// Expires 不是零值时设置过期时间，一般只需要设置MaxAge
+	Expires  time.Time
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+	// Partitioned 分区Cookie（CHIPS），第三方场景下按照顶级站点隔离，要求Secure
+	Partitioned bool
+}
+
+// cookieString 生成Set-Cookie响应头的值，名字不合法的时候返回 ErrInvalidCookieName
+func (o CookieOptions) cookieString(name string, value string) (string, error) {
+	path := o.Path
+	if path == "" {
+		path = "/"
+	}
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   o.Domain,
+		MaxAge:   o.MaxAge,
+		Expires:  o.Expires,
+		Secure:   o.Secure || o.Partitioned,
+		HttpOnly: o.HttpOnly,
+		SameSite: o.SameSite,
+	}
+	res := cookie.String()
+	// 名字不合法的时候http.Cookie直接返回空字符串
+	if res == "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCookieName, name)
+	}
+	if o.Partitioned {
+		res += "; Partitioned"
+	}
+	return res, nil
+}
+
+// Redirect 重定向到location
+// code 必须是3xx的状态码，或者是201 Created，否则直接panic，属于开发者的使用错误
+func (c *Context) Redirect(code int, location string) {
+	if (code < http.StatusMultipleChoices || code > http.StatusPermanentRedirect) && code != http.StatusCreated {
+		panic(fmt.Sprintf("web: 不能使用状态码 %d 重定向", code))
+	}
+	w := &responseCapture{ctx: c}
+	http.Redirect(w, c.request, location, code)
+	c.SetData(w.buf.Bytes())
+}
+
+// Cookie 获取请求中名为name的Cookie
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.request.Cookie(name)
+	if err != nil {
+		return "", fmt.Errorf("web: Cookie[%s]不存在", name)
+	}
+	return cookie.Value, nil
+}
+
+// SetCookie 设置Cookie，可以多次调用设置多个Cookie
+// 名字不合法的Cookie不会设置，只记录一条错误日志
+func (c *Context) SetCookie(name string, value string, opts CookieOptions) {
+	if err := c.setCookie(name, value, opts); err != nil {
+		c.Logger().Error("设置Cookie失败", F("error", err))
+	}
+}
+
+func (c *Context) setCookie(name string, value string, opts CookieOptions) error {
+	cookie, err := opts.cookieString(name, value)
+	if err != nil {
+		return err
+	}
+	c.header.Add("Set-Cookie", cookie)
+	return nil
+}
+
+// DeleteCookie 删除Cookie，Path和Domain必须和设置的时候一致才能删除成功
+func (c *Context) DeleteCookie(name string, opts CookieOptions) {
+	opts.MaxAge = -1
+	opts.Expires = time.Unix(0, 0)
+	c.SetCookie(name, "", opts)
+}
+
+// SetSignedCookie 设置签名的Cookie，内容是明文的，但是无法被篡改
+// 名字不合法的时候返回 ErrInvalidCookieName
+func (c *Context) SetSignedCookie(name string, value string, opts CookieOptions) error {
+	codec, err := c.cookieCodec()
+	if err != nil {
+		return err
+	}
+	return c.setCookie(name, codec.sign(name, value), opts)
+}
+
+// SignedCookie 获取签名的Cookie，签名校验失败返回 ErrInvalidCookie
+func (c *Context) SignedCookie(name string) (string, error) {
+	codec, err := c.cookieCodec()
+	if err != nil {
+		return "", err
+	}
+	value, err := c.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return codec.verify(name, value)
+}
+
+// SetEncryptedCookie 设置加密的Cookie，内容既不可见也无法被篡改
+// 名字不合法的时候返回 ErrInvalidCookieName
+func (c *Context) SetEncryptedCookie(name string, value string, opts CookieOptions) error {
+	codec, err := c.cookieCodec()
+	if err != nil {
+		return err
+	}
+	encrypted, err := codec.encrypt(name, value)
+	if err != nil {
+		return err
+	}
+	return c.setCookie(name, encrypted, opts)
+}
+
+// EncryptedCookie 获取加密的Cookie，解密失败返回 ErrInvalidCookie
+func (c *Context) EncryptedCookie(name string) (string, error) {
+	codec, err := c.cookieCodec()
+	if err != nil {
+		return "", err
+	}
+	value, err := c.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return codec.decrypt(name, value)
+}
+
+func (c *Context) cookieCodec() (*cookieCodec, error) {
+	if c.engine == nil || c.engine.cookieCodec == nil {
+		return nil, ErrNoCookieKeys
+	}
+	return c.engine.cookieCodec, nil
+}
+
+// WithCookieKeys 设置签名和加密Cookie使用的密钥
+// 第一个密钥用来签名和加密，所有的密钥都可以用来校验和解密
+// 轮换密钥的时候，把新密钥放在最前面，旧密钥往后放，等旧的Cookie都过期之后再把旧密钥删掉
+func WithCookieKeys(keys ...[]byte) HTTPOption {
+	return func(h *HTTPServer) {
+		h.cookieCodec = newCookieCodec(keys...)
+	}
+}
+
+// cookieCodec 负责Cookie的签名和加密
+type cookieCodec struct {
+	// hashKeys 签名使用的密钥
+	hashKeys [][]byte
+	// blocks 加密使用的AES-GCM
+	blocks []cipher.AEAD
+}
+
+// newCookieCodec 根据原始密钥派生出签名和加密使用的密钥
+// 这样原始密钥可以是任意长度，签名和加密也不会共用同一个密钥
+func newCookieCodec(keys ...[]byte) *cookieCodec {
+	if len(keys) == 0 {
+		panic("web: Cookie密钥不能为空")
+	}
+	codec := &cookieCodec{}
+	for _, key := range keys {
+		codec.hashKeys = append(codec.hashKeys, deriveKey(key, "sign"))
+		block, err := aes.NewCipher(deriveKey(key, "encrypt"))
+		if err != nil {
+			panic(err)
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			panic(err)
+		}
+		codec.blocks = append(codec.blocks, gcm)
+	}
+	return codec
+}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// sign 签名之后的格式：base64(value).base64(hmac(name|value))
+// name参与签名，防止把别的Cookie的值挪过来用
+func (c *cookieCodec) sign(name string, value string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(c.mac(c.hashKeys[0], name, payload))
+}
+
+func (c *cookieCodec) verify(name string, signed string) (string, error) {
+	payload, signature, ok := strings.Cut(signed, ".")
+	if !ok {
+		return "", ErrInvalidCookie
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	for _, key := range c.hashKeys {
+		if hmac.Equal(sig, c.mac(key, name, payload)) {
+			value, err := base64.RawURLEncoding.DecodeString(payload)
+			if err != nil {
+				return "", ErrInvalidCookie
+			}
+			return string(value), nil
+		}
+	}
+	return "", ErrInvalidCookie
+}
+
+func (c *cookieCodec) mac(key []byte, name string, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name + "|" + payload))
+	return mac.Sum(nil)
+}
+
+// encrypt 加密之后的格式：base64(nonce + ciphertext)，name作为附加数据参与认证
+func (c *cookieCodec) encrypt(name string, value string) (string, error) {
+	gcm := c.blocks[0]
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(value), []byte(name))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (c *cookieCodec) decrypt(name string, encrypted string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	for _, gcm := range c.blocks {
+		if len(data) < gcm.NonceSize() {
+			return "", ErrInvalidCookie
+		}
+		nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+		if value, err := gcm.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+			return string(value), nil
+		}
+	}
+	return "", ErrInvalidCookie
+}
@@ -0,0 +1,108 @@
+package bilibili_http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestContextRedirect 测试重定向
+func TestContextRedirect(t *testing.T) {
+	h := NewHTTP()
+	h.GET("/old", func(ctx *Context) {
+		ctx.Redirect(http.StatusMovedPermanently, "/new")
+	})
+	h.GET("/bad", func(ctx *Context) {
+		ctx.Redirect(http.StatusOK, "/new")
+	})
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/old", nil))
+	assert.Equal(t, http.StatusMovedPermanently, recorder.Code)
+	assert.Equal(t, "/new", recorder.Header().Get("Location"))
+
+	// 非法的状态码直接panic，由recovery兜底
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/bad", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+}
+
+// TestContextSetCookie 测试Cookie的各个属性
+func TestContextSetCookie(t *testing.T) {
+	ctx := NewContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	ctx.SetCookie("session", "abc", CookieOptions{MaxAge: 3600, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	ctx.SetCookie("widget", "1", CookieOptions{Path: "/embed", SameSite: http.SameSiteNoneMode, Partitioned: true})
+	ctx.DeleteCookie("old", CookieOptions{})
+	assert.Equal(t, []string{
+		"session=abc; Path=/; Max-Age=3600; HttpOnly; SameSite=Lax",
+		"widget=1; Path=/embed; Secure; SameSite=None; Partitioned",
+		"old=; Path=/; Expires=Thu, 01 Jan 1970 00:00:00 GMT; Max-Age=0",
+	}, ctx.ResponseHeader().Values("Set-Cookie"))
+
+	// 名字不合法的Cookie不设置，只记录日志
+	buf := &bytes.Buffer{}
+	ctx = NewContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	ctx.engine = NewHTTP(WithLogger(NewStdLogger(buf, LevelError)))
+	ctx.SetCookie("bad name", "v", CookieOptions{})
+	ctx.SetCookie("bad name", "v", CookieOptions{Partitioned: true})
+	assert.Empty(t, ctx.ResponseHeader().Values("Set-Cookie"))
+	assert.Contains(t, buf.String(), `level=ERROR msg=设置Cookie失败 method=GET path=/ error="web: 非法的Cookie名字: \"bad name\""`)
+}
+
+// TestContextSecureCookie 测试签名和加密的Cookie，以及密钥轮换
+func TestContextSecureCookie(t *testing.T) {
+	oldKey, newKey := []byte("old-secret"), []byte("new-secret")
+	newCtx := func(keys [][]byte, cookies []string) *Context {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, cookie := range cookies {
+			req.Header.Add("Cookie", cookie)
+		}
+		ctx := NewContext(httptest.NewRecorder(), req)
+		ctx.engine = NewHTTP(WithCookieKeys(keys...))
+		return ctx
+	}
+	// 旧密钥签发的Cookie
+	ctx := newCtx([][]byte{oldKey}, nil)
+	require.NoError(t, ctx.SetSignedCookie("user", "tom", CookieOptions{}))
+	require.NoError(t, ctx.SetEncryptedCookie("token", "secret", CookieOptions{}))
+	cookies := make([]string, 0, 2)
+	for _, c := range (&http.Response{Header: ctx.ResponseHeader()}).Cookies() {
+		cookies = append(cookies, c.Name+"="+c.Value)
+	}
+
+	// 轮换之后旧的Cookie依然有效
+	ctx = newCtx([][]byte{newKey, oldKey}, cookies)
+	user, err := ctx.SignedCookie("user")
+	require.NoError(t, err)
+	assert.Equal(t, "tom", user)
+	token, err := ctx.EncryptedCookie("token")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", token)
+
+	// 旧密钥被移除之后失效
+	ctx = newCtx([][]byte{newKey}, cookies)
+	_, err = ctx.SignedCookie("user")
+	assert.ErrorIs(t, err, ErrInvalidCookie)
+	_, err = ctx.EncryptedCookie("token")
+	assert.ErrorIs(t, err, ErrInvalidCookie)
+
+	// 篡改或者换了名字都会失效
+	ctx = newCtx([][]byte{oldKey}, []string{"user=" + cookies[0][len("user="):] + "x", "other=" + cookies[0][len("user="):]})
+	_, err = ctx.SignedCookie("user")
+	assert.ErrorIs(t, err, ErrInvalidCookie)
+	_, err = ctx.SignedCookie("other")
+	assert.ErrorIs(t, err, ErrInvalidCookie)
+
+	// 名字不合法
+	ctx = newCtx([][]byte{oldKey}, nil)
+	assert.ErrorIs(t, ctx.SetSignedCookie("bad name", "tom", CookieOptions{}), ErrInvalidCookieName)
+	assert.ErrorIs(t, ctx.SetEncryptedCookie("bad;name", "secret", CookieOptions{Partitioned: true}), ErrInvalidCookieName)
+	assert.Empty(t, ctx.ResponseHeader().Values("Set-Cookie"))
+
+	// 没有配置密钥
+	ctx = NewContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.ErrorIs(t, ctx.SetSignedCookie("user", "tom", CookieOptions{}), ErrNoCookieKeys)
+}
@@ -0,0 +1,260 @@
+package bilibili_http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrorType 错误的类型，可以组合使用：ErrorTypeBind | ErrorTypePublic
+type ErrorType uint8
+
+const (
+	// ErrorTypePrivate 内部错误，不应该把错误信息展示给客户端
+	ErrorTypePrivate ErrorType = 1 << iota
+	// ErrorTypePublic 可以展示给客户端的错误
+	ErrorTypePublic
+	// ErrorTypeBind 解析请求数据失败
+	ErrorTypeBind
+	// ErrorTypeRender 渲染响应数据失败
+	ErrorTypeRender
+	// ErrorTypeAny 匹配所有类型
+	ErrorTypeAny ErrorType = 1<<8 - 1
+)
+
+// Error 处理请求过程中记录下来的错误
+type Error struct {
+	Err  error
+	Type ErrorType
+	// Meta 附加信息，例如出错的字段
+	Meta any
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// SetType 设置错误类型
+func (e *Error) SetType(t ErrorType) *Error {
+	e.Type = t
+	return e
+}
+
+// SetMeta 设置附加信息
+func (e *Error) SetMeta(meta any) *Error {
+	e.Meta = meta
+	return e
+}
+
+// IsType 判断错误是否属于t类型
+func (e *Error) IsType(t ErrorType) bool {
+	return e.Type&t > 0
+}
+
+// ErrorList 错误列表
+type ErrorList []*Error
+
+// Last 最后一个错误，没有错误的时候返回nil
+func (l ErrorList) Last() *Error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l[len(l)-1]
+}
+
+// ByType 筛选出t类型的错误
+func (l ErrorList) ByType(t ErrorType) ErrorList {
+	res := make(ErrorList, 0, len(l))
+	for _, err := range l {
+		if err.IsType(t) {
+			res = append(res, err)
+		}
+	}
+	return res
+}
+
+// Errors 所有错误的错误信息
+func (l ErrorList) Errors() []string {
+	res := make([]string, 0, len(l))
+	for _, err := range l {
+		res = append(res, err.Error())
+	}
+	return res
+}
+
+func (l ErrorList) String() string {
+	var sb strings.Builder
+	for i, err := range l {
+		sb.WriteString(fmt.Sprintf("Error #%02d: %s\n", i+1, err.Error()))
+		if err.Meta != nil {
+			sb.WriteString(fmt.Sprintf("     Meta: %v\n", err.Meta))
+		}
+	}
+	return sb.String()
+}
+
+// Error 记录一个错误，不会中断请求，交给统一的错误处理中间件处理
+// 默认是 ErrorTypePrivate 类型，通过返回值可以修改类型和附加信息
+// ctx.Error(err).SetType(ErrorTypePublic).SetMeta("username")
+func (c *Context) Error(err error) *Error {
+	if err == nil {
+		panic("web: 错误不能为nil")
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Err: err, Type: ErrorTypePrivate}
+	}
+	c.mutex.Lock()
+	c.errors = append(c.errors, e)
+	c.mutex.Unlock()
+	return e
+}
+
+// Errors 获取记录下来的所有错误
+func (c *Context) Errors() ErrorList {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	res := make(ErrorList, len(c.errors))
+	copy(res, c.errors)
+	return res
+}
+
+// Abort 中断请求，后面还没有执行的中间件和视图函数都不会再执行
+// 注意：当前正在执行的函数以及外层中间件 next 之后的逻辑依然会继续执行，调用之后一般直接return
+func (c *Context) Abort() {
+	c.aborted = true
+}
+
+// IsAborted 请求是否已经被中断
+func (c *Context) IsAborted() bool {
+	return c.aborted
+}
+
+// AbortWithStatus 设置状态码并中断请求
+func (c *Context) AbortWithStatus(code int) {
+	c.SetStatusCode(code)
+	c.Abort()
+}
+
+// AbortWithStatusJSON 响应JSON数据并中断请求
+func (c *Context) AbortWithStatusJSON(code int, data any) {
+	c.Abort()
+	c.JSON(code, data)
+}
+
+// AbortWithError 设置状态码，记录错误并中断请求
+func (c *Context) AbortWithError(code int, err error) *Error {
+	c.AbortWithStatus(code)
+	return c.Error(err)
+}
+
+// HTTPError 携带了状态码的错误，ErrorHandler会根据它来响应
+// return NewHTTPError(http.StatusNotFound, "用户不存在").WithCause(err)
+type HTTPError struct {
+	// Status HTTP状态码
+	Status int `json:"-"`
+	// Code 业务错误码，默认和状态码一样
+	Code int `json:"code"`
+	// Message 展示给客户端的错误信息
+	Message string `json:"msg"`
+	// Cause 导致这个错误的原始错误，不会展示给客户端
+	Cause error `json:"-"`
+}
+
+// NewHTTPError 创建一个HTTPError，message为空时使用状态码对应的描述
+func NewHTTPError(status int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{
+		Status:  status,
+		Code:    status,
+		Message: message,
+	}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("web: %d %s: %s", e.Status, e.Message, e.Cause)
+	}
+	return fmt.Sprintf("web: %d %s", e.Status, e.Message)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Cause
+}
+
+// WithCode 设置业务错误码，返回一个新的HTTPError，可以放心地在预先定义好的错误上调用
+func (e *HTTPError) WithCode(code int) *HTTPError {
+	res := *e
+	res.Code = code
+	return &res
+}
+
+// WithCause 设置原始错误，返回一个新的HTTPError，可以放心地在预先定义好的错误上调用
+func (e *HTTPError) WithCause(err error) *HTTPError {
+	res := *e
+	res.Cause = err
+	return &res
+}
+
+// ErrorHandler 统一处理视图函数返回的错误
+type ErrorHandler func(ctx *Context, err error)
+
+// WithErrorHandler 设置统一处理视图函数返回的错误的方法
+func WithErrorHandler(handler ErrorHandler) HTTPOption {
+	return func(h *HTTPServer) {
+		h.errorHandler = handler
+	}
+}
+
+// E 把返回错误的视图函数转换成HandleFunc，返回的错误交给ErrorHandler处理
+// h.GET("/user/:id", E(func(ctx *Context) error { ... }))
+func E(fn ErrorHandleFunc) HandleFunc {
+	return func(ctx *Context) {
+		if err := fn(ctx); err != nil {
+			ctx.handleError(err)
+		}
+	}
+}
+
+// handleError 记录错误并交给ErrorHandler处理
+// HTTPError 是专门给客户端看的，所以记录为 ErrorTypePublic
+func (c *Context) handleError(err error) {
+	e := c.Error(err)
+	var he *HTTPError
+	if errors.As(err, &he) {
+		e.SetType(ErrorTypePublic)
+	}
+	handler := DefaultErrorHandler
+	if c.engine != nil && c.engine.errorHandler != nil {
+		handler = c.engine.errorHandler
+	}
+	handler(c, err)
+}
+
+// DefaultErrorHandler 默认的错误处理
+// HTTPError 按照它携带的状态码和信息响应，请求体过大响应413
+// 其他的错误一律响应500，不暴露错误的细节，只记录到日志中
+func DefaultErrorHandler(ctx *Context, err error) {
+	var he *HTTPError
+	switch {
+	case errors.As(err, &he):
+	case errors.Is(err, ErrRequestTooLarge):
+		he = NewHTTPError(http.StatusRequestEntityTooLarge, "")
+	default:
+		ctx.Logger().Error("请求处理失败", F("error", err))
+		he = NewHTTPError(http.StatusInternalServerError, "")
+	}
+	status := he.Status
+	// 直接构造的HTTPError可能没有设置状态码，WriteHeader(0) 会panic
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	ctx.JSON(status, he)
+}
@@ -0,0 +1,176 @@
+package bilibili_http
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestContextAbort 测试中断请求之后，后面的中间件和视图函数不再执行
+func TestContextAbort(t *testing.T) {
+	var logs []string
+	record := func(name string) MiddlewareHandleFunc {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				logs = append(logs, name+" in")
+				next(ctx)
+				logs = append(logs, name+" out")
+			}
+		}
+	}
+	auth := func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			if ctx.Request().Header.Get("Authorization") == "" {
+				// 深层的辅助函数中中断请求
+				func() {
+					ctx.AbortWithStatusJSON(http.StatusUnauthorized, H{"msg": "未登录"})
+				}()
+			}
+			next(ctx)
+		}
+	}
+	h := NewHTTP()
+	h.GET("/user", func(ctx *Context) {
+		logs = append(logs, "handler")
+		ctx.TEXT(http.StatusOK, "tom")
+	}, record("outer"), auth, record("inner"))
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, `{"msg":"未登录"}`, recorder.Body.String())
+	assert.Equal(t, []string{"outer in", "outer out"}, logs)
+
+	logs = nil
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Authorization", "token")
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, []string{"outer in", "inner in", "handler", "inner out", "outer out"}, logs)
+}
+
+// TestContextErrors 测试错误的积累
+func TestContextErrors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{"))
+	req.Header.Set("Content-Type", MIMEJSON)
+	ctx := NewContext(httptest.NewRecorder(), req)
+	assert.False(t, ctx.Written())
+
+	dbErr := errors.New("db down")
+	ctx.Error(dbErr)
+	ctx.Error(errors.New("用户名不能为空")).SetType(ErrorTypePublic).SetMeta("username")
+	var user struct{}
+	assert.Error(t, ctx.BindJSON(&user))
+	assert.Error(t, ctx.Bind(&user))
+	assert.True(t, ctx.IsAborted())
+	assert.True(t, ctx.Written())
+	assert.Equal(t, http.StatusBadRequest, ctx.StatusCode())
+
+	errs := ctx.Errors()
+	assert.Len(t, errs, 3)
+	assert.ErrorIs(t, errs[0], dbErr)
+	assert.Len(t, errs.ByType(ErrorTypePublic), 1)
+	assert.Len(t, errs.ByType(ErrorTypeBind), 1)
+	assert.Len(t, errs.ByType(ErrorTypePublic|ErrorTypeBind), 2)
+	assert.True(t, errs.Last().IsType(ErrorTypeBind))
+	assert.Equal(t, "Error #01: db down\nError #02: 用户名不能为空\n     Meta: username\nError #03: unexpected EOF\n", errs.String())
+}
+
+// TestErrorHandleFunc 测试返回错误的视图函数和统一的错误处理
+func TestErrorHandleFunc(t *testing.T) {
+	errUserNotFound := NewHTTPError(http.StatusNotFound, "用户不存在").WithCode(10001)
+	dbErr := errors.New("db down")
+	testCases := []struct {
+		name     string
+		opts     []HTTPOption
+		handler  ErrorHandleFunc
+		wantCode int
+		wantBody string
+		wantLog  string
+	}{
+		{
+			name: "no error",
+			handler: func(ctx *Context) error {
+				ctx.TEXT(http.StatusOK, "tom")
+				return nil
+			},
+			wantCode: http.StatusOK,
+			wantBody: "tom",
+		},
+		{
+			name: "http error",
+			handler: func(ctx *Context) error {
+				return errUserNotFound.WithCause(dbErr)
+			},
+			wantCode: http.StatusNotFound,
+			wantBody: `{"code":10001,"msg":"用户不存在"}`,
+		},
+		{
+			// 普通的错误不暴露细节
+			name: "internal error",
+			handler: func(ctx *Context) error {
+				return dbErr
+			},
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"code":500,"msg":"Internal Server Error"}`,
+			// 错误的细节记录到日志中，方便排查
+			wantLog: `level=ERROR msg=请求处理失败 method=GET path=/user route=/user error="db down"`,
+		},
+		{
+			// 没有设置状态码的HTTPError按照500处理
+			name: "http error without status",
+			handler: func(ctx *Context) error {
+				return &HTTPError{Code: 10002, Message: "数据库出错", Cause: dbErr}
+			},
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"code":10002,"msg":"数据库出错"}`,
+		},
+		{
+			name: "custom error handler",
+			opts: []HTTPOption{WithErrorHandler(func(ctx *Context, err error) {
+				ctx.TEXT(http.StatusServiceUnavailable, err.Error())
+			})},
+			handler: func(ctx *Context) error {
+				return dbErr
+			},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "db down",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := NewHTTP(append([]HTTPOption{WithLogger(NewStdLogger(buf, LevelError))}, tc.opts...)...)
+			var errs ErrorList
+			h.GET("/user", E(tc.handler), func(next HandleFunc) HandleFunc {
+				return func(ctx *Context) {
+					next(ctx)
+					errs = ctx.Errors()
+				}
+			})
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			if tc.wantLog == "" {
+				assert.Empty(t, buf.String())
+			} else {
+				assert.Contains(t, buf.String(), tc.wantLog)
+			}
+			if tc.wantCode != http.StatusOK {
+				// 错误会被记录下来，方便日志中间件使用
+				assert.Len(t, errs, 1)
+				assert.ErrorIs(t, errs[0], dbErr)
+			}
+		})
+	}
+
+	assert.Equal(t, "web: 404 用户不存在: db down", errUserNotFound.WithCause(dbErr).Error())
+	assert.Nil(t, errUserNotFound.Cause)
+}
@@ -0,0 +1,117 @@
+package bilibili_http
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"time"
+)
+
+// responseCapture 把写到http.ResponseWriter中的数据转存到上下文中
+// 这样就能直接复用标准库中操作ResponseWriter的方法，例如 http.ServeContent
+// 而不用担心和flush中间件重复写入响应
+type responseCapture struct {
+	ctx *Context
+	buf bytes.Buffer
+}
+
+func (w *responseCapture) Header() http.Header {
+	return w.ctx.header
+}
+
+func (w *responseCapture) WriteHeader(code int) {
+	w.ctx.SetStatusCode(code)
+}
+
+func (w *responseCapture) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+// responseStreamer 直接把数据写到ResponseWriter中，用来响应文件这类可能很大的内容
+// 写入状态码的时候就提交了响应，之后flush中间件和ServeHTTP都不会再写一次
+type responseStreamer struct {
+	ctx *Context
+}
+
+func (w *responseStreamer) Header() http.Header {
+	return w.ctx.header
+}
+
+func (w *responseStreamer) WriteHeader(code int) {
+	if w.ctx.committed {
+		return
+	}
+	w.ctx.SetStatusCode(code)
+	w.ctx.commitHeader()
+}
+
+func (w *responseStreamer) Write(p []byte) (int, error) {
+	if !w.ctx.committed {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ctx.response.Write(p)
+	w.ctx.size += n
+	return n, err
+}
+
+// ServeContent 响应content中的内容，支持Range、If-Range、If-Match、If-None-Match、
+// If-Modified-Since、If-Unmodified-Since这些请求头，会根据情况响应200、206、304、412或者416
+// name 用来推断Content-Type，响应头中已经有Content-Type的时候不会再推断
+// modtime 不是零值的时候会设置Last-Modified
+// 响应头中提前设置好ETag的话，也会参与条件请求的判断
+func (c *Context) ServeContent(name string, modtime time.Time, content io.ReadSeeker) {
+	w := &responseCapture{ctx: c}
+	http.ServeContent(w, c.request, name, modtime, content)
+	c.SetData(w.buf.Bytes())
+}
+
+// File 响应磁盘上的文件
+func (c *Context) File(filepath string) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		c.TEXT(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		c.TEXT(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return
+	}
+	c.streamContent(info.Name(), info.ModTime(), f)
+}
+
+// FileFromFS 响应fsys中的文件，fsys可以是 embed.FS
+func (c *Context) FileFromFS(name string, fsys fs.FS) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		c.TEXT(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		c.TEXT(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		// 不支持Seek的文件只能一次性读到内存中
+		data, err := io.ReadAll(f)
+		if err != nil {
+			c.TEXT(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+	c.streamContent(path.Base(name), info.ModTime(), content)
+}
+
+// streamContent 和 ServeContent 一样，只不过内容直接写到连接上，不会缓存在上下文中
+// 响应体不再经过flush中间件的转换，适合文件这类比较大的内容
+func (c *Context) streamContent(name string, modtime time.Time, content io.ReadSeeker) {
+	http.ServeContent(&responseStreamer{ctx: c}, c.request, name, modtime, content)
+}
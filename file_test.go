@@ -0,0 +1,132 @@
+package bilibili_http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestContextServeContent 测试Range请求和条件请求
+func TestContextServeContent(t *testing.T) {
+	modtime := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	content := "0123456789"
+	h := NewHTTP()
+	h.GET("/data", func(ctx *Context) {
+		ctx.SetHeader("Etag", `"v1"`)
+		ctx.ServeContent("data.txt", modtime, strings.NewReader(content))
+	})
+	testCases := []struct {
+		name   string
+		header map[string]string
+
+		wantCode        int
+		wantBody        string
+		wantContentType string
+		wantRange       string
+	}{
+		{
+			name:            "full",
+			wantCode:        http.StatusOK,
+			wantBody:        content,
+			wantContentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:      "range",
+			header:    map[string]string{"Range": "bytes=2-4"},
+			wantCode:  http.StatusPartialContent,
+			wantBody:  "234",
+			wantRange: "bytes 2-4/10",
+		},
+		{
+			name:            "multiple ranges",
+			header:          map[string]string{"Range": "bytes=0-1,5-6"},
+			wantCode:        http.StatusPartialContent,
+			wantContentType: "multipart/byteranges",
+		},
+		{
+			name:     "unsatisfiable range",
+			header:   map[string]string{"Range": "bytes=20-30"},
+			wantCode: http.StatusRequestedRangeNotSatisfiable,
+		},
+		{
+			name:     "if none match",
+			header:   map[string]string{"If-None-Match": `"v0", "v1"`},
+			wantCode: http.StatusNotModified,
+		},
+		{
+			name:     "if modified since",
+			header:   map[string]string{"If-Modified-Since": modtime.Format(http.TimeFormat)},
+			wantCode: http.StatusNotModified,
+		},
+		{
+			name:      "if range matched",
+			header:    map[string]string{"Range": "bytes=0-0", "If-Range": `"v1"`},
+			wantCode:  http.StatusPartialContent,
+			wantBody:  "0",
+			wantRange: "bytes 0-0/10",
+		},
+		{
+			name:     "if range changed",
+			header:   map[string]string{"Range": "bytes=0-0", "If-Range": `"v0"`},
+			wantCode: http.StatusOK,
+			wantBody: content,
+		},
+		{
+			name:     "if match failed",
+			header:   map[string]string{"If-Match": `"v0"`},
+			wantCode: http.StatusPreconditionFailed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/data", nil)
+			for key, value := range tc.header {
+				req.Header.Set(key, value)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, recorder.Body.String())
+			}
+			if tc.wantContentType != "" {
+				assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), tc.wantContentType))
+			}
+			if tc.wantRange != "" {
+				assert.Equal(t, tc.wantRange, recorder.Header().Get("Content-Range"))
+			}
+		})
+	}
+}
+
+// TestContextFile 测试响应磁盘上的文件
+func TestContextFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "hello.html")
+	require.NoError(t, os.WriteFile(file, []byte("<h1>hello</h1>"), 0o600))
+	h := NewHTTP()
+	h.GET("/file", func(ctx *Context) {
+		ctx.File(file)
+	})
+	h.GET("/missing", func(ctx *Context) {
+		ctx.File(filepath.Join(dir, "missing"))
+	})
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/file", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "<h1>hello</h1>", recorder.Body.String())
+	assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "bytes", recorder.Header().Get("Accept-Ranges"))
+
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
@@ -0,0 +1,115 @@
+package bilibili_http
+
+import (
+	"fmt"
+	"time"
+)
+
+// 上下文中的键值对，一般用于中间件和视图函数之间传递数据
+// 例如鉴权中间件把当前登录的用户放进去，后面的视图函数直接取出来用
+// 读写都加了锁，视图函数中另外开启的goroutine也可以安全地使用
+
+// RequestIDKey 请求ID在键值对中的key
+// middlewares/requestid 写入，访问日志、recovery和 Context.Logger 读取
+const RequestIDKey = "web.request_id"
+
+// RequestID 获取当前请求的ID
+// 优先使用 middlewares/requestid 保存的，其次是其他方式设置在响应头 X-Request-ID 中的，都没有的时候为空
+// 请求头中的值没有经过校验，不会使用
+func (c *Context) RequestID() string {
+	if id := c.GetString(RequestIDKey); id != "" {
+		return id
+	}
+	return c.header.Get("X-Request-ID")
+}
+
+// Set 保存一个键值对
+func (c *Context) Set(key string, value any) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.keys == nil {
+		c.keys = make(map[string]any)
+	}
+	c.keys[key] = value
+}
+
+// Get 获取key对应的值，第二个返回值表示key是否存在
+func (c *Context) Get(key string) (any, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	value, ok := c.keys[key]
+	return value, ok
+}
+
+// MustGet 获取key对应的值，key不存在直接panic
+func (c *Context) MustGet(key string) any {
+	value, ok := c.Get(key)
+	if !ok {
+		panic(fmt.Sprintf("web: [%s]不存在", key))
+	}
+	return value
+}
+
+// GetString 获取string类型的值，key不存在或者类型不对返回零值
+func (c *Context) GetString(key string) string {
+	value, _ := Value[string](c, key)
+	return value
+}
+
+// GetBool 获取bool类型的值，key不存在或者类型不对返回零值
+func (c *Context) GetBool(key string) bool {
+	value, _ := Value[bool](c, key)
+	return value
+}
+
+// GetInt 获取int类型的值，key不存在或者类型不对返回零值
+func (c *Context) GetInt(key string) int {
+	value, _ := Value[int](c, key)
+	return value
+}
+
+// GetInt64 获取int64类型的值，key不存在或者类型不对返回零值
+func (c *Context) GetInt64(key string) int64 {
+	value, _ := Value[int64](c, key)
+	return value
+}
+
+// GetFloat64 获取float64类型的值，key不存在或者类型不对返回零值
+func (c *Context) GetFloat64(key string) float64 {
+	value, _ := Value[float64](c, key)
+	return value
+}
+
+// GetTime 获取time.Time类型的值，key不存在或者类型不对返回零值
+func (c *Context) GetTime(key string) time.Time {
+	value, _ := Value[time.Time](c, key)
+	return value
+}
+
+// GetDuration 获取time.Duration类型的值，key不存在或者类型不对返回零值
+func (c *Context) GetDuration(key string) time.Duration {
+	value, _ := Value[time.Duration](c, key)
+	return value
+}
+
+// GetStringSlice 获取[]string类型的值，key不存在或者类型不对返回nil
+func (c *Context) GetStringSlice(key string) []string {
+	value, _ := Value[[]string](c, key)
+	return value
+}
+
+// Value 获取key对应的值并转换成T类型
+// key不存在或者类型不对的时候，第二个返回值为false
+// user, ok := Value[*User](ctx, "user")
+func Value[T any](ctx *Context, key string) (T, bool) {
+	var zero T
+	value, ok := ctx.Get(key)
+	if !ok {
+		return zero, false
+	}
+	res, ok := value.(T)
+	if !ok {
+		return zero, false
+	}
+	return res, true
+}
@@ -0,0 +1,73 @@
+package bilibili_http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestContextKeys 测试上下文中的键值对
+func TestContextKeys(t *testing.T) {
+	type User struct {
+		Name string
+	}
+	now := time.Now()
+	ctx := NewContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	ctx.Set("user", &User{Name: "tom"})
+	ctx.Set("name", "tom")
+	ctx.Set("age", 18)
+	ctx.Set("login", now)
+	ctx.Set("ttl", time.Minute)
+
+	user, ok := Value[*User](ctx, "user")
+	assert.True(t, ok)
+	assert.Equal(t, "tom", user.Name)
+	_, ok = Value[User](ctx, "user")
+	assert.False(t, ok)
+	_, ok = Value[string](ctx, "missing")
+	assert.False(t, ok)
+
+	assert.Equal(t, "tom", ctx.GetString("name"))
+	assert.Equal(t, 18, ctx.GetInt("age"))
+	assert.Equal(t, "", ctx.GetString("age"))
+	assert.Equal(t, now, ctx.GetTime("login"))
+	assert.Equal(t, time.Minute, ctx.GetDuration("ttl"))
+	assert.Equal(t, "tom", ctx.MustGet("name"))
+	assert.PanicsWithValue(t, "web: [missing]不存在", func() {
+		ctx.MustGet("missing")
+	})
+}
+
+// TestContextKeysConcurrent 测试在多个goroutine中读写键值对
+func TestContextKeysConcurrent(t *testing.T) {
+	ctx := NewContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("key-%d", i)
+			ctx.Set(key, i)
+			assert.Equal(t, i, ctx.GetInt(key))
+		}(i)
+	}
+	wg.Wait()
+	assert.Len(t, ctx.keys, 10)
+}
+
+// TestContextRequestID 测试请求ID的来源，请求头中没有校验过的值不会使用
+func TestContextRequestID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "forged")
+	ctx := NewContext(httptest.NewRecorder(), req)
+	assert.Equal(t, "", ctx.RequestID())
+	ctx.SetHeader("X-Request-ID", "from-header")
+	assert.Equal(t, "from-header", ctx.RequestID())
+	ctx.Set(RequestIDKey, "abc-123")
+	assert.Equal(t, "abc-123", ctx.RequestID())
+}
@@ -0,0 +1,206 @@
+package bilibili_http
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level 日志级别
+type Level int8
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", l)
+}
+
+// Field 结构化日志中的一个字段
+type Field struct {
+	Key   string
+	Value any
+}
+
+// F 创建一个日志字段
+// logger.Info("注册路由", F("method", "GET"), F("pattern", "/user/:id"))
+func F(key string, value any) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Logger 框架内部使用的日志接口
+// 实现这个接口就可以把框架的日志接入到zap、logrus、slog之类的日志库中
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+	// With 返回一个新的Logger，之后的每一条日志都会带上fields
+	With(fields ...Field) Logger
+	// Enabled 是否会输出level级别的日志，准备日志字段的开销比较大时先判断一下
+	Enabled(level Level) bool
+}
+
+// WithLogger 设置框架使用的Logger，测试中可以使用 NopLogger 关掉日志
+// logger为nil时等同于 NopLogger
+func WithLogger(logger Logger) HTTPOption {
+	return func(h *HTTPServer) {
+		if logger == nil {
+			logger = NopLogger()
+		}
+		h.logger = logger
+	}
+}
+
+// stdLogger 默认的Logger，按照 logfmt 的格式输出
+// time=2023-05-01T12:00:00+08:00 level=INFO msg=注册路由 method=GET pattern=/user/:id
+type stdLogger struct {
+	// mutex 多个With出来的Logger共用一把锁，保证一行日志不会被打断
+	mutex  *sync.Mutex
+	w      io.Writer
+	level  Level
+	fields []Field
+}
+
+// NewStdLogger 创建一个输出到w中的Logger，低于level的日志直接丢弃
+func NewStdLogger(w io.Writer, level Level) Logger {
+	return &stdLogger{
+		mutex: &sync.Mutex{},
+		w:     w,
+		level: level,
+	}
+}
+
+// defaultLogger 输出到标准错误，只输出Info及以上的日志
+func defaultLogger() Logger {
+	return NewStdLogger(os.Stderr, LevelInfo)
+}
+
+func (l *stdLogger) Debug(msg string, fields ...Field) {
+	l.log(LevelDebug, msg, fields)
+}
+
+func (l *stdLogger) Info(msg string, fields ...Field) {
+	l.log(LevelInfo, msg, fields)
+}
+
+func (l *stdLogger) Warn(msg string, fields ...Field) {
+	l.log(LevelWarn, msg, fields)
+}
+
+func (l *stdLogger) Error(msg string, fields ...Field) {
+	l.log(LevelError, msg, fields)
+}
+
+func (l *stdLogger) With(fields ...Field) Logger {
+	res := *l
+	res.fields = make([]Field, 0, len(l.fields)+len(fields))
+	res.fields = append(res.fields, l.fields...)
+	res.fields = append(res.fields, fields...)
+	return &res
+}
+
+func (l *stdLogger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+func (l *stdLogger) log(level Level, msg string, fields []Field) {
+	if !l.Enabled(level) {
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString("time=")
+	sb.WriteString(time.Now().Format(time.RFC3339))
+	sb.WriteString(" level=")
+	sb.WriteString(level.String())
+	sb.WriteString(" msg=")
+	sb.WriteString(logfmtValue(msg))
+	for _, fields := range [][]Field{l.fields, fields} {
+		for _, field := range fields {
+			sb.WriteByte(' ')
+			sb.WriteString(field.Key)
+			sb.WriteByte('=')
+			sb.WriteString(logfmtValue(fmt.Sprint(field.Value)))
+		}
+	}
+	sb.WriteByte('\n')
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	_, _ = io.WriteString(l.w, sb.String())
+}
+
+// logfmtValue 包含空格、引号、等号或者换行的值需要加上引号
+func logfmtValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+	if strings.ContainsAny(value, " \"=\n\t") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// nopLogger 丢弃所有的日志
+type nopLogger struct{}
+
+// NopLogger 不输出任何日志的Logger，一般在测试中使用
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Debug(string, ...Field) {}
+
+func (nopLogger) Info(string, ...Field) {}
+
+func (nopLogger) Warn(string, ...Field) {}
+
+func (nopLogger) Error(string, ...Field) {}
+
+func (n nopLogger) With(...Field) Logger {
+	return n
+}
+
+func (nopLogger) Enabled(Level) bool {
+	return false
+}
+
+// Logger 带上了当前请求信息的Logger
+// 在视图函数和中间件中记录日志的时候使用，方便把同一个请求的日志串起来
+// 请求ID是后面才设置的话，会重新创建一个带上请求ID的Logger
+func (c *Context) Logger() Logger {
+	id := c.RequestID()
+	if c.logger == nil || c.loggerRequestID != id {
+		logger := defaultLogger()
+		if c.engine != nil && c.engine.logger != nil {
+			logger = c.engine.logger
+		}
+		fields := []Field{F("method", c.Method), F("path", c.Pattern)}
+		if c.route != "" {
+			fields = append(fields, F("route", c.route))
+		}
+		if id != "" {
+			fields = append(fields, F("request_id", id))
+		}
+		c.logger = logger.With(fields...)
+		c.loggerRequestID = id
+	}
+	return c.logger
+}
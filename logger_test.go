@@ -0,0 +1,80 @@
+package bilibili_http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStdLogger 测试默认Logger的格式和级别
+func TestStdLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewStdLogger(buf, LevelInfo)
+	logger.Debug("看不到")
+	logger.With(F("app", "user")).Warn("慢请求", F("latency", "1.5 s"), F("path", "/user"))
+	timeRegexp := regexp.MustCompile(`time=\S+ `)
+	assert.Equal(t, `level=WARN msg=慢请求 app=user latency="1.5 s" path=/user`+"\n", timeRegexp.ReplaceAllString(buf.String(), ""))
+	assert.False(t, logger.Enabled(LevelDebug))
+	assert.True(t, logger.Enabled(LevelError))
+}
+
+// TestContextLogger 测试框架内部和上下文中使用的Logger
+func TestContextLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHTTP(WithLogger(NewStdLogger(buf, LevelDebug)))
+	h.GET("/user/:id", func(ctx *Context) {
+		ctx.Logger().Info("查询用户")
+		panic("db down")
+	})
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user/1", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+
+	logs := buf.String()
+	assert.Contains(t, logs, "level=DEBUG msg=注册路由 method=GET pattern=/user/:id\n")
+	assert.Contains(t, logs, "level=DEBUG msg=处理请求 method=GET path=/user/1 route=/user/:id\n")
+	assert.Contains(t, logs, "level=INFO msg=查询用户 method=GET path=/user/1 route=/user/:id\n")
+	assert.Contains(t, logs, "level=ERROR msg=请求处理过程中发生panic method=GET path=/user/1 route=/user/:id error=\"db down\" stack=")
+
+	// 请求ID是在Logger创建之后才设置的，也要带上
+	buf.Reset()
+	h = NewHTTP(WithLogger(NewStdLogger(buf, LevelDebug)))
+	h.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			ctx.Set(RequestIDKey, "abc-123")
+			next(ctx)
+		}
+	})
+	h.GET("/user/:id", func(ctx *Context) {
+		assert.Equal(t, "abc-123", ctx.RequestID())
+		ctx.Logger().Info("查询用户")
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/1", nil))
+	assert.Contains(t, buf.String(), "level=INFO msg=查询用户 method=GET path=/user/1 route=/user/:id request_id=abc-123\n")
+
+	// 测试中可以关掉日志
+	h = NewHTTP(WithLogger(NopLogger()))
+	h.GET("/user/:id", func(ctx *Context) {
+		ctx.Logger().Error("看不到")
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/1", nil))
+}
+
+// TestWithNilLogger 测试传入nil的时候关掉日志，而不是在注册路由的时候空指针panic
+func TestWithNilLogger(t *testing.T) {
+	h := NewHTTP(WithLogger(nil))
+	assert.NotPanics(t, func() {
+		h.GET("/user/:id", func(ctx *Context) {
+			ctx.Logger().Info("看不到")
+			ctx.TEXT(http.StatusOK, "tom")
+		})
+	})
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user/1", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "tom", recorder.Body.String())
+}
@@ -0,0 +1,276 @@
+package bilibili_http
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mapping.go 负责把 map[string][]string 形式的数据（查询参数、表单、路由参数）映射到结构体上
+// 结构体字段通过tag指定对应的key，例如：
+// type User struct {
+//	Name  string   `form:"name"`
+//	Age   int      `form:"age"`
+//	Tags  []string `form:"tags"`
+//	Birth time.Time `form:"birth" time_format:"2006-01-02"`
+// }
+// 没有写tag的字段直接使用字段名，tag为"-"的字段直接跳过
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	fileHeaderType = reflect.TypeOf(multipart.FileHeader{})
+)
+
+// mapForm 将values和files中的数据写入到dest中
+// dest 必须是结构体指针
+// strict 为true时，values中存在结构体没有声明的key会直接报错
+// tagged 为true时，只有显式写了tag的字段才会被赋值，不会再退回到字段名，
+// 用于查询参数、路由参数这类客户端可以随意构造的数据，避免覆盖请求体中的字段
+func mapForm(dest any, values map[string][]string, files map[string][]*multipart.FileHeader, tag string, strict, tagged bool) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("web: 解析目标必须是非空指针")
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return errors.New("web: 解析目标必须是结构体指针")
+	}
+	used := make(map[string]struct{}, len(values))
+	if err := mapStruct(rv, values, files, tag, tagged, used); err != nil {
+		return err
+	}
+	if !strict {
+		return nil
+	}
+	for key := range values {
+		if _, ok := used[key]; !ok {
+			return fmt.Errorf("web: 未知字段[%s]", key)
+		}
+	}
+	return nil
+}
+
+func mapStruct(rv reflect.Value, values map[string][]string, files map[string][]*multipart.FileHeader, tag string, tagged bool, used map[string]struct{}) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		name, ok := field.Tag.Lookup(tag)
+		if name == "-" {
+			continue
+		}
+		// 匿名嵌套的结构体：把它的字段当成当前结构体的字段
+		if field.Anonymous && !ok {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+				if ft.Kind() != reflect.Struct {
+					continue
+				}
+				if fv.IsNil() {
+					if !fv.CanSet() {
+						continue
+					}
+					fv.Set(reflect.New(ft))
+				}
+				fv = fv.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := mapStruct(fv, values, files, tag, tagged, used); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if idx := strings.Index(name, ","); idx >= 0 {
+			name = name[:idx]
+		}
+		if name == "" {
+			if tagged {
+				continue
+			}
+			name = field.Name
+		}
+		if fhs, ok := files[name]; ok && isFileField(field.Type) {
+			used[name] = struct{}{}
+			setFileField(fv, fhs)
+			continue
+		}
+		vals, ok := values[name]
+		if !ok {
+			continue
+		}
+		used[name] = struct{}{}
+		if err := setField(fv, field, vals); err != nil {
+			return fmt.Errorf("web: 字段[%s]解析失败: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func isFileField(t reflect.Type) bool {
+	switch {
+	case t == fileHeaderType:
+		return true
+	case t.Kind() == reflect.Pointer:
+		return t.Elem() == fileHeaderType
+	case t.Kind() == reflect.Slice:
+		return isFileField(t.Elem())
+	}
+	return false
+}
+
+func setFileField(fv reflect.Value, fhs []*multipart.FileHeader) {
+	if len(fhs) == 0 {
+		return
+	}
+	switch fv.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(fv.Type(), len(fhs), len(fhs))
+		for i, fh := range fhs {
+			setFileField(slice.Index(i), []*multipart.FileHeader{fh})
+		}
+		fv.Set(slice)
+	case reflect.Pointer:
+		fv.Set(reflect.ValueOf(fhs[0]))
+	default:
+		fv.Set(reflect.ValueOf(*fhs[0]))
+	}
+}
+
+func setField(fv reflect.Value, field reflect.StructField, vals []string) error {
+	switch fv.Kind() {
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			// []byte 当成一个整体处理
+			fv.SetBytes([]byte(vals[0]))
+			return nil
+		}
+		slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setValue(slice.Index(i), field, val); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	case reflect.Array:
+		if len(vals) != fv.Len() {
+			return fmt.Errorf("期望%d个值，实际%d个", fv.Len(), len(vals))
+		}
+		for i, val := range vals {
+			if err := setValue(fv.Index(i), field, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return setValue(fv, field, vals[0])
+}
+
+// setValue 把单个字符串转换成字段对应的类型
+func setValue(fv reflect.Value, field reflect.StructField, val string) error {
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setValue(fv.Elem(), field, val)
+	}
+	if fv.CanAddr() {
+		if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok && fv.Type() != timeType {
+			return u.UnmarshalText([]byte(val))
+		}
+	}
+	switch fv.Type() {
+	case timeType:
+		return setTime(fv, field, val)
+	case durationType:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		if val == "" {
+			val = "false"
+		}
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if val == "" {
+			val = "0"
+		}
+		n, err := strconv.ParseInt(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if val == "" {
+			val = "0"
+		}
+		n, err := strconv.ParseUint(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if val == "" {
+			val = "0"
+		}
+		f, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Interface:
+		fv.Set(reflect.ValueOf(val))
+	default:
+		return fmt.Errorf("不支持的类型 %s", fv.Type())
+	}
+	return nil
+}
+
+// setTime 时间类型默认按照RFC3339解析
+// 可以通过 time_format 指定格式，time_format:"unix" 表示时间戳
+func setTime(fv reflect.Value, field reflect.StructField, val string) error {
+	if val == "" {
+		fv.Set(reflect.ValueOf(time.Time{}))
+		return nil
+	}
+	layout := field.Tag.Get("time_format")
+	switch layout {
+	case "":
+		layout = time.RFC3339
+	case "unix":
+		sec, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(time.Unix(sec, 0)))
+		return nil
+	}
+	t, err := time.ParseInLocation(layout, val, time.Local)
+	if err != nil {
+		return err
+	}
+	fv.Set(reflect.ValueOf(t))
+	return nil
+}
@@ -1,56 +1,62 @@
 package bilibili_http
 
-import (
-	"fmt"
-	"net/http"
-	"runtime"
-	"strings"
-)
-
 // MiddlewareHandleFunc 中间件的函数签名
 // 参数 HandleFunc 是下一次需要执行的中间件逻辑
 // 返回值 HandleFunc 是当前的中间件逻辑
 type MiddlewareHandleFunc func(next HandleFunc) HandleFunc
 
-// flush 统一刷新数据到响应对象中
-func flush() MiddlewareHandleFunc {
-	return func(next HandleFunc) HandleFunc {
-		return func(ctx *Context) {
-			defer func() {
-				// 写入状态码
-				ctx.response.WriteHeader(ctx.status)
-				// 写入响应头
-				for key, value := range ctx.header {
-					ctx.response.Header().Set(key, value)
-				}
-				// 写入响应体
-				_, _ = ctx.response.Write(ctx.data)
-			}()
-			next(ctx)
+// abortable 包装下一个要执行的中间件或者视图函数，请求被中断之后就不再执行
+// 这样中间件不需要关心前面有没有人调用过Abort，照常调用next即可
+func abortable(next HandleFunc) HandleFunc {
+	return func(ctx *Context) {
+		if ctx.IsAborted() {
+			return
 		}
+		next(ctx)
 	}
 }
 
-// recovery 兜底的错误恢复
-func recovery() MiddlewareHandleFunc {
+// ResponseTransformer 在响应写入之前修改响应，例如统一包装成 {"code":0,"data":...} 的格式
+// 通过 ctx.ResponseData、ctx.ResponseHeader 读取响应，通过 ctx.SetData、ctx.SetHeader 修改响应
+type ResponseTransformer func(ctx *Context)
+
+// Flush 统一刷新数据到响应对象中，内置的flush中间件就是 Flush()
+// transformers 在写入之前按照顺序调用，流式响应在视图函数中就已经写出去了，不会再经过它们
+// 需要调整和recovery的顺序的时候，使用 middlewares/flush 包
+func Flush(transformers ...ResponseTransformer) MiddlewareHandleFunc {
 	return func(next HandleFunc) HandleFunc {
 		return func(ctx *Context) {
-			// 这个defer负责hook住所有的panic错误
-			defer func() {
-				if err := recover(); err != nil {
-					// 下面的是输出给客户端看的
-					ctx.SetStatusCode(http.StatusInternalServerError)
-					ctx.SetData([]byte("Server Internal Error, Please Try Again Later!"))
-					// 下面的输出给开发者看的
-					fmt.Println(trace(fmt.Sprintf("%s\n", err)))
-					return
-				}
-			}()
 			next(ctx)
+			// 后面panic了的话不会走到这里，交给外层的recovery响应500
+			if ctx.Committed() {
+				return
+			}
+			for _, fn := range transformers {
+				fn(ctx)
+			}
+			// 自动推断Content-Type和设置Content-Length都由Commit完成
+			ctx.Commit()
 		}
 	}
 }
 
+// WithoutDefaultMiddleware 去掉内置的flush和recovery中间件
+// 响应依然会在请求处理完之后写入，但是panic不会再被恢复
+func WithoutDefaultMiddleware() HTTPOption {
+	return func(h *HTTPServer) {
+		h.defaultMiddlewares = nil
+	}
+}
+
+// WithDefaultMiddleware 用mids替换内置的flush和recovery中间件
+// 它们在全局中间件之前执行，例如替换成自定义的recovery：
+// NewHTTP(WithDefaultMiddleware(myRecovery()))
+func WithDefaultMiddleware(mids ...MiddlewareHandleFunc) HTTPOption {
+	return func(h *HTTPServer) {
+		h.defaultMiddlewares = mids
+	}
+}
+
 /*
 flush和recovery两个中间件的优先级问题
 recovery应该是咱们整个框架的兜底操作，就是放在最前
@@ -58,20 +64,6 @@ flush是统一刷新数据的，至少也是放在比较靠前的位置
 recovery《flush
 */
 
-func trace(message string) string {
-	var pcs [32]uintptr
-	n := runtime.Callers(3, pcs[:]) // skip first 3 caller
-
-	var str strings.Builder
-	str.WriteString(message + "\nTraceback:")
-	for _, pc := range pcs[:n] {
-		fn := runtime.FuncForPC(pc)
-		file, line := fn.FileLine(pc)
-		str.WriteString(fmt.Sprintf("\n\t%s:%d", file, line))
-	}
-	return str.String()
-}
-
 // accessLog 框架层面记录请求或响应信息
 //func accesslog() MiddlewareHandleFunc {
 //	return func(next HandleFunc) HandleFunc {
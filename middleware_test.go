@@ -0,0 +1,70 @@
+package bilibili_http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHTTPServerUse 测试全局中间件，没有匹配到路由的请求也会执行
+func TestHTTPServerUse(t *testing.T) {
+	var logs []string
+	h := NewHTTP()
+	h.GET("/user", func(ctx *Context) {
+		logs = append(logs, "handler")
+		ctx.TEXT(http.StatusOK, "tom")
+	})
+	h.RouterGroup.Use(recordMiddleware(&logs, "root group"))
+	h.Use(recordMiddleware(&logs, "global"))
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, []string{"global", "root group", "handler"}, logs)
+
+	logs = nil
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/order", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, []string{"global"}, logs)
+}
+
+// TestDefaultMiddleware 测试替换和去掉内置的中间件
+func TestDefaultMiddleware(t *testing.T) {
+	handler := func(ctx *Context) {
+		if ctx.Request().URL.Query().Get("panic") != "" {
+			panic("boom")
+		}
+		ctx.TEXT(http.StatusOK, "tom")
+	}
+
+	// 去掉内置中间件之后，响应依然会写回去，但是panic不会被恢复
+	h := NewHTTP(WithoutDefaultMiddleware())
+	h.GET("/user", handler)
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "tom", recorder.Body.String())
+	assert.PanicsWithValue(t, "boom", func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user?panic=1", nil))
+	})
+
+	// 替换成自定义的recovery
+	h = NewHTTP(WithDefaultMiddleware(Flush(), func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			defer func() {
+				if err := recover(); err != nil {
+					ctx.TEXT(http.StatusServiceUnavailable, "稍后再试")
+				}
+			}()
+			next(ctx)
+		}
+	}))
+	h.GET("/user", handler)
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user?panic=1", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "稍后再试", recorder.Body.String())
+}
@@ -1,49 +1,207 @@
 package accesslog
 
 import (
-	"encoding/json"
-	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
 	"github.com/borntodie-new/bilibili-http"
 )
 
+// MiddlewareBuilder 访问日志中间件
+// 推荐注册成全局中间件，这样404的请求也会被记录下来：
+// h.Use(accesslog.NewMiddleware(nil).Output(w).Format(accesslog.CombinedFormat).Build())
 type MiddlewareBuilder struct {
 	logFunc func(information string)
+	// output 设置了之后直接把日志写到output中，不再调用logFunc
+	output io.Writer
+	format FormatFunc
+	// skipPaths 不记录的请求路径，例如健康检查
+	skipPaths map[string]struct{}
+	skipFuncs []func(ctx *bilibili_http.Context, entry *Entry) bool
+	// sampleRate 正常请求的采样率，出错的请求总是会记录下来
+	sampleRate float64
+	random     *rand.Rand
+	randMutex  sync.Mutex
+}
+
+// Output 日志写入w，每条日志一行
+// 写入的开销比较大的时候可以配合 NewAsyncWriter 使用
+func (m *MiddlewareBuilder) Output(w io.Writer) *MiddlewareBuilder {
+	m.output = w
+	return m
+}
+
+// Format 设置日志格式，默认是 JSONFormat
+func (m *MiddlewareBuilder) Format(format FormatFunc) *MiddlewareBuilder {
+	m.format = format
+	return m
+}
+
+// SkipPaths 不记录这些路径的请求，完全匹配请求路径
+func (m *MiddlewareBuilder) SkipPaths(paths ...string) *MiddlewareBuilder {
+	for _, path := range paths {
+		m.skipPaths[path] = struct{}{}
+	}
+	return m
+}
+
+// SkipStatus 不记录这些状态码的请求，例如 http.StatusNotFound
+func (m *MiddlewareBuilder) SkipStatus(codes ...int) *MiddlewareBuilder {
+	return m.Skip(func(ctx *bilibili_http.Context, entry *Entry) bool {
+		for _, code := range codes {
+			if entry.Status == code {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Skip 自定义的跳过规则，返回true就不记录这个请求
+// 在请求处理完之后调用，entry中已经有了状态码、耗时等信息
+func (m *MiddlewareBuilder) Skip(fn func(ctx *bilibili_http.Context, entry *Entry) bool) *MiddlewareBuilder {
+	m.skipFuncs = append(m.skipFuncs, fn)
+	return m
+}
+
+// Sample 按照rate的比例记录正常的请求，取值范围 (0, 1]
+// 状态码大于等于400或者有错误的请求不受影响，总是会记录下来
+func (m *MiddlewareBuilder) Sample(rate float64) *MiddlewareBuilder {
+	if rate <= 0 || rate > 1 {
+		panic("web: 采样率的取值范围是 (0, 1]")
+	}
+	m.sampleRate = rate
+	return m
 }
 
 func (m *MiddlewareBuilder) Build() bilibili_http.MiddlewareHandleFunc {
 	return func(next bilibili_http.HandleFunc) bilibili_http.HandleFunc {
 		return func(ctx *bilibili_http.Context) {
+			if _, ok := m.skipPaths[ctx.Pattern]; ok {
+				next(ctx)
+				return
+			}
+			start := time.Now()
+			completed := false
 			defer func() {
 				// 记录我们想要保存当前请求想要保存的信息
-				// 如果我们想要记录命中的路由是什么，那从哪里拿呢？
 				// 在这个作用域中，我们能和外界取得联系的只有Context上下文，所以我们只能通过Context获取到信息
-				// 具体操作就是在Context中新增一个属性，在这里取就好
-				l := accessLog{
-					Method:  ctx.Method,
-					Pattern: ctx.Pattern,
+				entry := newEntry(ctx, start)
+				// 视图函数panic了，外层的recovery会响应500，这里提前按照500记录
+				if !completed {
+					entry.Status = http.StatusInternalServerError
+					entry.Bytes = 0
 				}
-				data, _ := json.Marshal(l)
-				m.logFunc(string(data))
+				if m.skip(ctx, entry) {
+					return
+				}
+				m.write(m.format(entry))
 			}()
 			next(ctx)
+			completed = true
+		}
+	}
+}
+
+func (m *MiddlewareBuilder) skip(ctx *bilibili_http.Context, entry *Entry) bool {
+	for _, fn := range m.skipFuncs {
+		if fn(ctx, entry) {
+			return true
 		}
 	}
+	if m.sampleRate >= 1 || entry.Status >= http.StatusBadRequest || len(entry.Errors) > 0 {
+		return false
+	}
+	m.randMutex.Lock()
+	defer m.randMutex.Unlock()
+	return m.random.Float64() >= m.sampleRate
+}
+
+func (m *MiddlewareBuilder) write(line []byte) {
+	if m.output == nil {
+		m.logFunc(string(line))
+		return
+	}
+	buf := make([]byte, 0, len(line)+1)
+	buf = append(buf, line...)
+	buf = append(buf, '\n')
+	_, _ = m.output.Write(buf)
 }
 
+// NewMiddleware logFunc 接收格式化好的一条日志，为nil的时候输出到标准输出
+// 设置了 Output 之后不再使用logFunc
 func NewMiddleware(logFunc func(information string)) *MiddlewareBuilder {
+	m := &MiddlewareBuilder{
+		logFunc:    logFunc,
+		format:     JSONFormat,
+		skipPaths:  map[string]struct{}{},
+		sampleRate: 1,
+		random:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
 	if logFunc == nil {
-		logFunc = func(information string) {
-			fmt.Println(information)
-		}
+		m.output = os.Stdout
 	}
-	return &MiddlewareBuilder{
-		logFunc: logFunc,
+	return m
+}
+
+// Entry 一条访问日志
+type Entry struct {
+	// Time 请求开始的时间
+	Time time.Time
+	// Method 请求的方法
+	Method string
+	// Pattern 请求的路径
+	Pattern string
+	// Query 查询参数，没有的话为空
+	Query string
+	// Route 匹配到的路由，例如 /user/:id，没有匹配到的时候为空
+	Route string
+	// Proto 协议版本，例如 HTTP/1.1
+	Proto string
+	// Status 响应状态码
+	Status int
+	// Bytes 响应体的大小，流式响应和文件是实际写入的字节数
+	Bytes int
+	// Latency 处理请求的耗时
+	Latency time.Duration
+	// ClientIP 客户端IP，参考 bilibili_http.WithTrustedProxies
+	ClientIP  string
+	UserAgent string
+	Referer   string
+	// RequestID 请求ID，没有的话为空
+	RequestID string
+	// Errors 处理请求过程中记录下来的错误
+	Errors []string
+}
+
+func newEntry(ctx *bilibili_http.Context, start time.Time) *Entry {
+	req := ctx.Request()
+	return &Entry{
+		Time:      start,
+		Method:    ctx.Method,
+		Pattern:   ctx.Pattern,
+		Query:     req.URL.RawQuery,
+		Route:     ctx.Route(),
+		Proto:     req.Proto,
+		Status:    ctx.StatusCode(),
+		Bytes:     ctx.ResponseSize(),
+		Latency:   time.Since(start),
+		ClientIP:  ctx.ClientIP(),
+		UserAgent: req.UserAgent(),
+		Referer:   req.Referer(),
+		RequestID: ctx.RequestID(),
+		Errors:    ctx.Errors().Errors(),
 	}
 }
 
-// accessLog 日志抽象结构体，可自定义
-// 这里只是简单的示例
-type accessLog struct {
-	Method  string `json:"method"`  // 请求的方法
-	Pattern string `json:"pattern"` // 请求的路径
+// URI 请求的路径加上查询参数
+func (e *Entry) URI() string {
+	if e.Query == "" {
+		return e.Pattern
+	}
+	return e.Pattern + "?" + e.Query
 }
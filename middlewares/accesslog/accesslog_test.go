@@ -0,0 +1,271 @@
+package accesslog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/borntodie-new/bilibili-http"
+)
+
+// TestMiddleware 测试记录的字段以及跳过的规则
+func TestMiddleware(t *testing.T) {
+	format := Template(`{{.Method}} {{.URI}} {{.Route}} {{.Status}} {{.Bytes}} {{.ClientIP}} {{.RequestID}} {{.Errors}}`)
+	testCases := []struct {
+		name    string
+		builder func(m *MiddlewareBuilder)
+		method  string
+		url     string
+
+		wantLog string
+	}{
+		{
+			name:    "ok",
+			method:  http.MethodGet,
+			url:     "/user/1?fields=name",
+			wantLog: "GET /user/1?fields=name /user/:id 200 3 192.0.2.1 abc-123 []\n",
+		},
+		{
+			// 全局中间件也会记录没有匹配到路由的请求
+			name:    "not found",
+			method:  http.MethodGet,
+			url:     "/order/1",
+			wantLog: "GET /order/1  404 25 192.0.2.1  []\n",
+		},
+		{
+			name:    "error",
+			method:  http.MethodGet,
+			url:     "/error",
+			wantLog: "GET /error /error 200 0 192.0.2.1  [db down]\n",
+		},
+		{
+			// 视图函数panic了，按照外层recovery的响应记录
+			name:    "panic",
+			method:  http.MethodGet,
+			url:     "/panic",
+			wantLog: "GET /panic /panic 500 0 192.0.2.1  []\n",
+		},
+		{
+			// 流式响应记录实际写入的字节数
+			name:    "stream",
+			method:  http.MethodGet,
+			url:     "/stream",
+			wantLog: "GET /stream /stream 200 11 192.0.2.1  []\n",
+		},
+		{
+			name:   "skip paths",
+			method: http.MethodGet,
+			url:    "/healthz",
+			builder: func(m *MiddlewareBuilder) {
+				m.SkipPaths("/healthz")
+			},
+		},
+		{
+			name:   "skip status",
+			method: http.MethodGet,
+			url:    "/order/1",
+			builder: func(m *MiddlewareBuilder) {
+				m.SkipStatus(http.StatusNotFound)
+			},
+		},
+		{
+			name:   "skip func",
+			method: http.MethodGet,
+			url:    "/user/1",
+			builder: func(m *MiddlewareBuilder) {
+				m.Skip(func(ctx *bilibili_http.Context, entry *Entry) bool {
+					return entry.Route == "/user/:id"
+				})
+			},
+		},
+		{
+			name:   "skip func not matched",
+			method: http.MethodGet,
+			url:    "/stream",
+			builder: func(m *MiddlewareBuilder) {
+				m.SkipPaths("/healthz").SkipStatus(http.StatusNotFound)
+			},
+			wantLog: "GET /stream /stream 200 11 192.0.2.1  []\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			m := NewMiddleware(nil).Output(buf).Format(format)
+			if tc.builder != nil {
+				tc.builder(m)
+			}
+			h := bilibili_http.NewHTTP(bilibili_http.WithLogger(bilibili_http.NopLogger()))
+			h.Use(m.Build())
+			h.GET("/user/:id", func(ctx *bilibili_http.Context) {
+				ctx.Set(bilibili_http.RequestIDKey, "abc-123")
+				ctx.TEXT(http.StatusOK, "tom")
+			})
+			h.GET("/healthz", func(ctx *bilibili_http.Context) {
+				ctx.TEXT(http.StatusOK, "ok")
+			})
+			h.GET("/error", func(ctx *bilibili_http.Context) {
+				ctx.Error(errors.New("db down"))
+			})
+			h.GET("/panic", func(ctx *bilibili_http.Context) {
+				ctx.TEXT(http.StatusOK, "partial")
+				panic("db down")
+			})
+			h.GET("/stream", func(ctx *bilibili_http.Context) {
+				ctx.Stream(func(w io.Writer) bool {
+					_, _ = io.WriteString(w, "data: 1\n\n\n\n")
+					return false
+				})
+			})
+			req := httptest.NewRequest(tc.method, tc.url, nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, tc.wantLog, buf.String())
+		})
+	}
+}
+
+// TestMiddlewareSample 测试采样，出错的请求总是会记录下来
+func TestMiddlewareSample(t *testing.T) {
+	var logs []string
+	m := NewMiddleware(func(information string) {
+		logs = append(logs, information)
+	}).Format(Template(`{{.Status}}`)).Sample(0.1)
+	m.random = rand.New(rand.NewSource(1))
+	h := bilibili_http.NewHTTP(bilibili_http.WithLogger(bilibili_http.NopLogger()))
+	h.Use(m.Build())
+	h.GET("/user", func(ctx *bilibili_http.Context) {
+		ctx.TEXT(http.StatusOK, "tom")
+	})
+	h.GET("/error", func(ctx *bilibili_http.Context) {
+		ctx.Error(errors.New("db down"))
+	})
+	for i := 0; i < 1000; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user", nil))
+	}
+	assert.InDelta(t, 100, len(logs), 40)
+	for _, log := range logs {
+		assert.Equal(t, "200", log)
+	}
+
+	logs = nil
+	for i := 0; i < 10; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/error", nil))
+	}
+	assert.Len(t, logs, 20)
+
+	for _, rate := range []float64{0, -1, 1.5} {
+		assert.PanicsWithValue(t, "web: 采样率的取值范围是 (0, 1]", func() {
+			NewMiddleware(nil).Sample(rate)
+		})
+	}
+}
+
+// TestFormat 测试内置的日志格式
+func TestFormat(t *testing.T) {
+	entry := &Entry{
+		Time:      time.Date(2023, 5, 1, 12, 0, 0, 0, time.FixedZone("CST", 8*3600)),
+		Method:    http.MethodGet,
+		Pattern:   "/user/1",
+		Query:     "fields=name",
+		Route:     "/user/:id",
+		Proto:     "HTTP/1.1",
+		Status:    http.StatusOK,
+		Bytes:     3,
+		Latency:   1500 * time.Microsecond,
+		ClientIP:  "192.0.2.1",
+		UserAgent: "curl/8.0",
+		Referer:   "",
+		RequestID: "abc-123",
+		Errors:    []string{"db down", "cache miss"},
+	}
+	empty := &Entry{
+		Time:    entry.Time,
+		Method:  http.MethodHead,
+		Pattern: "/",
+		Proto:   "HTTP/1.1",
+		Status:  http.StatusNoContent,
+	}
+	testCases := []struct {
+		name   string
+		format FormatFunc
+		entry  *Entry
+		want   string
+	}{
+		{
+			name:   "common",
+			format: CommonFormat,
+			entry:  entry,
+			want:   `192.0.2.1 - - [01/May/2023:12:00:00 +0800] "GET /user/1?fields=name HTTP/1.1" 200 3`,
+		},
+		{
+			// 没有响应体和客户端IP的时候使用 -
+			name:   "common empty",
+			format: CommonFormat,
+			entry:  empty,
+			want:   `- - - [01/May/2023:12:00:00 +0800] "HEAD / HTTP/1.1" 204 -`,
+		},
+		{
+			name:   "combined",
+			format: CombinedFormat,
+			entry:  entry,
+			want:   `192.0.2.1 - - [01/May/2023:12:00:00 +0800] "GET /user/1?fields=name HTTP/1.1" 200 3 "-" "curl/8.0"`,
+		},
+		{
+			name:   "json",
+			format: JSONFormat,
+			entry:  entry,
+			want: `{"time":"2023-05-01T12:00:00+08:00","method":"GET","pattern":"/user/1","query":"fields=name",` +
+				`"route":"/user/:id","proto":"HTTP/1.1","status":200,"bytes":3,"latency_ms":1.5,"client_ip":"192.0.2.1",` +
+				`"user_agent":"curl/8.0","request_id":"abc-123","errors":["db down","cache miss"]}`,
+		},
+		{
+			name:   "json empty",
+			format: JSONFormat,
+			entry:  empty,
+			want: `{"time":"2023-05-01T12:00:00+08:00","method":"HEAD","pattern":"/","proto":"HTTP/1.1",` +
+				`"status":204,"bytes":0,"latency_ms":0,"client_ip":""}`,
+		},
+		{
+			name:   "logfmt",
+			format: LogfmtFormat,
+			entry:  entry,
+			want: `time=2023-05-01T12:00:00+08:00 method=GET path="/user/1?fields=name" route=/user/:id status=200 ` +
+				`bytes=3 latency=1.5ms client_ip=192.0.2.1 user_agent=curl/8.0 request_id=abc-123 errors="db down; cache miss"`,
+		},
+		{
+			name:   "logfmt empty",
+			format: LogfmtFormat,
+			entry:  empty,
+			want:   `time=2023-05-01T12:00:00+08:00 method=HEAD path=/ status=204 bytes=0 latency=0s`,
+		},
+		{
+			name:   "template",
+			format: Template("{{.Method}} {{.URI}} {{.Status}} {{.Latency}}\n"),
+			entry:  entry,
+			want:   `GET /user/1?fields=name 200 1.5ms`,
+		},
+		{
+			name:   "template error",
+			format: Template("{{.Missing}}"),
+			entry:  entry,
+			want:   `web: 访问日志模板执行失败 template: accesslog:1:2: executing "accesslog" at <.Missing>: can't evaluate field Missing in type *accesslog.Entry`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, string(tc.format(tc.entry)))
+		})
+	}
+	assert.Panics(t, func() {
+		Template("{{.Method")
+	})
+}
@@ -0,0 +1,150 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// FormatFunc 把一条访问日志格式化成一行，结尾不需要换行
+type FormatFunc func(entry *Entry) []byte
+
+// CommonFormat Apache 的 Common Log Format
+// 127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326
+func CommonFormat(entry *Entry) []byte {
+	var buf bytes.Buffer
+	writeCommon(&buf, entry)
+	return buf.Bytes()
+}
+
+// CombinedFormat Apache 的 Combined Log Format，在 CommonFormat 的基础上加上了 Referer 和 User-Agent
+func CombinedFormat(entry *Entry) []byte {
+	var buf bytes.Buffer
+	writeCommon(&buf, entry)
+	fmt.Fprintf(&buf, " %s %s", quote(entry.Referer), quote(entry.UserAgent))
+	return buf.Bytes()
+}
+
+func writeCommon(buf *bytes.Buffer, entry *Entry) {
+	bytesSent := "-"
+	if entry.Bytes > 0 {
+		bytesSent = strconv.Itoa(entry.Bytes)
+	}
+	fmt.Fprintf(buf, "%s - - [%s] %s %d %s",
+		dash(entry.ClientIP),
+		entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		quote(entry.Method+" "+entry.URI()+" "+entry.Proto),
+		entry.Status,
+		bytesSent)
+}
+
+// jsonEntry JSON格式中的字段，耗时使用毫秒
+type jsonEntry struct {
+	Time      string   `json:"time"`
+	Method    string   `json:"method"`
+	Pattern   string   `json:"pattern"`
+	Query     string   `json:"query,omitempty"`
+	Route     string   `json:"route,omitempty"`
+	Proto     string   `json:"proto"`
+	Status    int      `json:"status"`
+	Bytes     int      `json:"bytes"`
+	LatencyMS float64  `json:"latency_ms"`
+	ClientIP  string   `json:"client_ip"`
+	UserAgent string   `json:"user_agent,omitempty"`
+	Referer   string   `json:"referer,omitempty"`
+	RequestID string   `json:"request_id,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// JSONFormat 每条日志一个JSON对象，也就是 JSON Lines 格式
+func JSONFormat(entry *Entry) []byte {
+	data, _ := json.Marshal(jsonEntry{
+		Time:      entry.Time.Format(time.RFC3339Nano),
+		Method:    entry.Method,
+		Pattern:   entry.Pattern,
+		Query:     entry.Query,
+		Route:     entry.Route,
+		Proto:     entry.Proto,
+		Status:    entry.Status,
+		Bytes:     entry.Bytes,
+		LatencyMS: float64(entry.Latency) / float64(time.Millisecond),
+		ClientIP:  entry.ClientIP,
+		UserAgent: entry.UserAgent,
+		Referer:   entry.Referer,
+		RequestID: entry.RequestID,
+		Errors:    entry.Errors,
+	})
+	return data
+}
+
+// LogfmtFormat key=value 的格式，和框架默认的Logger保持一致
+func LogfmtFormat(entry *Entry) []byte {
+	var buf bytes.Buffer
+	pairs := [][2]string{
+		{"time", entry.Time.Format(time.RFC3339)},
+		{"method", entry.Method},
+		{"path", entry.URI()},
+		{"route", entry.Route},
+		{"status", strconv.Itoa(entry.Status)},
+		{"bytes", strconv.Itoa(entry.Bytes)},
+		{"latency", entry.Latency.String()},
+		{"client_ip", entry.ClientIP},
+		{"user_agent", entry.UserAgent},
+		{"referer", entry.Referer},
+		{"request_id", entry.RequestID},
+		{"errors", strings.Join(entry.Errors, "; ")},
+	}
+	for _, pair := range pairs {
+		// 空的字段直接省略，避免一行里面全是 key=""
+		if pair[1] == "" {
+			continue
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(pair[0])
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(pair[1]))
+	}
+	return buf.Bytes()
+}
+
+// Template 使用 text/template 自定义格式，模板中可以使用 Entry 的所有字段和方法
+// accesslog.Template(`{{.Method}} {{.URI}} {{.Status}} {{.Latency}}`)
+// 模板有语法错误的时候直接panic
+func Template(text string) FormatFunc {
+	tpl := template.Must(template.New("accesslog").Parse(text))
+	return func(entry *Entry) []byte {
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, entry); err != nil {
+			return []byte(fmt.Sprintf("web: 访问日志模板执行失败 %v", err))
+		}
+		return bytes.TrimRight(buf.Bytes(), "\n")
+	}
+}
+
+// quote CLF中的字符串，空的字符串使用 "-"
+func quote(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	return strconv.Quote(s)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func logfmtValue(value string) string {
+	if strings.ContainsAny(value, " \"=\n\t") {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,234 @@
+package accesslog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AsyncWriter 异步写入日志，请求不会因为写日志而变慢
+// 日志先放到缓冲队列中，由后台的goroutine批量写入，队列满了之后直接丢弃
+// 程序退出之前需要调用 Close，否则队列中的日志会丢失
+// 多条日志会合并成一次写入，每次写入的都是完整的日志，配合 RotateWriter 使用时一条日志不会被切分到两个文件中
+type AsyncWriter struct {
+	w       io.Writer
+	queue   chan []byte
+	done    chan struct{}
+	mutex   sync.RWMutex
+	closed  bool
+	dropped int64
+}
+
+// NewAsyncWriter size 是队列的长度，也就是最多能缓冲多少条日志
+func NewAsyncWriter(w io.Writer, size int) *AsyncWriter {
+	if size <= 0 {
+		size = 1024
+	}
+	a := &AsyncWriter{
+		w:     w,
+		queue: make(chan []byte, size),
+		done:  make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Write 把p放入队列，不会阻塞
+// p会被复制一份，调用之后可以继续使用
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	if a.closed {
+		return 0, os.ErrClosed
+	}
+	buf := make([]byte, len(p))
+	copy(buf, p)
+	select {
+	case a.queue <- buf:
+	default:
+		atomic.AddInt64(&a.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// Dropped 因为队列满了被丢弃的日志条数
+func (a *AsyncWriter) Dropped() int64 {
+	return atomic.LoadInt64(&a.dropped)
+}
+
+// Close 写完队列中剩下的日志，如果底层的Writer实现了 io.Closer 也会一起关闭
+func (a *AsyncWriter) Close() error {
+	a.mutex.Lock()
+	if a.closed {
+		a.mutex.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.queue)
+	a.mutex.Unlock()
+	<-a.done
+	if closer, ok := a.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// asyncBatchSize 一次合并写入的日志最多多少字节，超过这个大小的单条日志单独写入
+const asyncBatchSize = 4096
+
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+	batch := make([]byte, 0, asyncBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		// 一批日志一次写完，不能像bufio.Writer那样在缓冲区的边界把一条日志拆成两次写入
+		_, _ = a.w.Write(batch)
+		batch = batch[:0]
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case p, ok := <-a.queue:
+			if !ok {
+				flush()
+				return
+			}
+			// 放不下的话先把之前的写出去，再开始新的一批
+			if len(batch)+len(p) > asyncBatchSize {
+				flush()
+			}
+			batch = append(batch, p...)
+			// 队列空了就刷一次，流量小的时候日志也能及时落地
+			if len(a.queue) == 0 {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// RotateWriter 按照大小和时间切分的日志文件
+// 切分的时候把当前的文件重命名为 文件名.时间，然后重新创建一个文件继续写
+type RotateWriter struct {
+	filename string
+	// maxSize 单个文件的最大字节数，0表示不按照大小切分
+	maxSize int64
+	// interval 单个文件最长写多久，0表示不按照时间切分
+	interval time.Duration
+	mutex    sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// NewRotateWriter 打开filename，不存在的话会创建，已经存在的话追加写入
+func NewRotateWriter(filename string, maxSize int64, interval time.Duration) (*RotateWriter, error) {
+	r := &RotateWriter{
+		filename: filename,
+		maxSize:  maxSize,
+		interval: interval,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotateWriter) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.shouldRotate(len(p)) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate 手动切分，例如收到 SIGHUP 的时候
+func (r *RotateWriter) Rotate() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.rotate()
+}
+
+func (r *RotateWriter) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *RotateWriter) shouldRotate(n int) bool {
+	// 空文件没有必要切分，单条日志超过maxSize的时候也只能写进去
+	if r.size == 0 {
+		return false
+	}
+	if r.maxSize > 0 && r.size+int64(n) > r.maxSize {
+		return true
+	}
+	return r.interval > 0 && time.Since(r.openedAt) >= r.interval
+}
+
+func (r *RotateWriter) rotate() error {
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			return err
+		}
+		r.file = nil
+	}
+	if err := os.Rename(r.filename, r.backupName()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return r.open()
+}
+
+// backupName 同一秒内切分多次的时候加上序号，避免覆盖之前的文件
+func (r *RotateWriter) backupName() string {
+	name := fmt.Sprintf("%s.%s", r.filename, time.Now().Format("20060102-150405"))
+	res := name
+	for i := 1; ; i++ {
+		if _, err := os.Stat(res); os.IsNotExist(err) {
+			return res
+		}
+		res = fmt.Sprintf("%s.%d", name, i)
+	}
+}
+
+func (r *RotateWriter) open() error {
+	if dir := filepath.Dir(r.filename); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(r.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	r.openedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,190 @@
+package accesslog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingWriter 第一次写入之后阻塞，直到release被关闭
+type blockingWriter struct {
+	mutex   sync.Mutex
+	buf     bytes.Buffer
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+	closed  bool
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.started)
+	})
+	<-w.release
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *blockingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+// TestAsyncWriter 测试异步写入，队列满了之后丢弃，关闭的时候写完剩下的日志
+func TestAsyncWriter(t *testing.T) {
+	w := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+	a := NewAsyncWriter(w, 1)
+	line := []byte("a\n")
+	n, err := a.Write(line)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	// 调用方复用缓冲区不会影响已经放入队列的日志
+	line[0] = 'x'
+	<-w.started
+
+	// 后台的goroutine阻塞在写入上，队列中只能放一条
+	for _, p := range []string{"b\n", "c\n", "d\n"} {
+		n, err = a.Write([]byte(p))
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+	}
+	assert.Equal(t, int64(2), a.Dropped())
+
+	close(w.release)
+	require.NoError(t, a.Close())
+	assert.Equal(t, "a\nb\n", w.buf.String())
+	assert.True(t, w.closed)
+
+	_, err = a.Write([]byte("e\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+	assert.NoError(t, a.Close())
+}
+
+// TestRotateWriter 测试按照大小、时间以及手动切分
+func TestRotateWriter(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "logs", "access.log")
+	backups := func() []string {
+		matches, err := filepath.Glob(filename + ".*")
+		require.NoError(t, err)
+		sort.Strings(matches)
+		res := make([]string, 0, len(matches))
+		for _, match := range matches {
+			data, err := os.ReadFile(match)
+			require.NoError(t, err)
+			res = append(res, string(data))
+		}
+		return res
+	}
+	current := func() string {
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// 按照大小切分，目录不存在的时候自动创建
+	r, err := NewRotateWriter(filename, 10, 0)
+	require.NoError(t, err)
+	for _, line := range []string{"12345\n", "abcd\n", "67890\n"} {
+		_, err = r.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"12345\n", "abcd\n"}, backups())
+	assert.Equal(t, "67890\n", current())
+	// 单条日志超过maxSize的时候也要写进去
+	_, err = r.Write([]byte("0123456789abcdef\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef\n", current())
+	require.NoError(t, r.Close())
+	require.NoError(t, r.Close())
+	_, err = r.Write([]byte("closed\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+
+	// 重新打开的时候追加写入，已有的大小也算在内
+	r, err = NewRotateWriter(filename, 20, 0)
+	require.NoError(t, err)
+	_, err = r.Write([]byte("xyz\n"))
+	require.NoError(t, err)
+	assert.Len(t, backups(), 4)
+	assert.Equal(t, "xyz\n", current())
+	require.NoError(t, r.Close())
+
+	// 按照时间切分
+	require.NoError(t, os.RemoveAll(dir))
+	r, err = NewRotateWriter(filename, 0, time.Hour)
+	require.NoError(t, err)
+	_, err = r.Write([]byte("old\n"))
+	require.NoError(t, err)
+	_, err = r.Write([]byte("same file\n"))
+	require.NoError(t, err)
+	assert.Empty(t, backups())
+	r.openedAt = r.openedAt.Add(-time.Hour)
+	_, err = r.Write([]byte("new\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old\nsame file\n"}, backups())
+	assert.Equal(t, "new\n", current())
+
+	// 手动切分
+	require.NoError(t, r.Rotate())
+	assert.Equal(t, []string{"old\nsame file\n", "new\n"}, backups())
+	assert.Equal(t, "", current())
+	require.NoError(t, r.Close())
+}
+
+// gateWriter 第一次写入之前阻塞，直到release被关闭，之后原样写入w
+type gateWriter struct {
+	w       io.Writer
+	release chan struct{}
+}
+
+func (g *gateWriter) Write(p []byte) (int, error) {
+	<-g.release
+	return g.w.Write(p)
+}
+
+// TestAsyncWriterRotate 测试异步批量写入的时候，一条日志不会被切分到两个文件中
+func TestAsyncWriterRotate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "access.log")
+	r, err := NewRotateWriter(filename, 4000, 0)
+	require.NoError(t, err)
+	g := &gateWriter{w: r, release: make(chan struct{})}
+	a := NewAsyncWriter(g, 64)
+	// 每条日志1500字节，几条日志合在一起就会跨过批量写入的大小
+	lines := make([]string, 0, 20)
+	for i := 0; i < 20; i++ {
+		line := fmt.Sprintf("%02d %s\n", i, strings.Repeat("a", 1496))
+		lines = append(lines, line)
+		_, err = a.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	close(g.release)
+	require.NoError(t, a.Close())
+	assert.Zero(t, a.Dropped())
+
+	matches, err := filepath.Glob(filename + "*")
+	require.NoError(t, err)
+	got := make([]string, 0, len(lines))
+	for _, match := range matches {
+		data, err := os.ReadFile(match)
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(string(data), "\n"), "%s 中有不完整的日志", match)
+		for _, line := range strings.SplitAfter(string(data), "\n") {
+			if line != "" {
+				got = append(got, line)
+			}
+		}
+	}
+	assert.Greater(t, len(matches), 1)
+	assert.ElementsMatch(t, lines, got)
+}
@@ -0,0 +1,274 @@
+package cors
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/borntodie-new/bilibili-http"
+)
+
+// MiddlewareBuilder 跨域资源共享中间件
+// 预检请求在这里直接响应，不会走到视图函数，所以不需要给每个路由注册OPTIONS
+// 必须注册成全局中间件，没有注册OPTIONS的路由也要经过它：
+// h.Use(cors.NewMiddleware().AllowOrigins("https://*.example.com").AllowCredentials().Build())
+type MiddlewareBuilder struct {
+	// allowAll 允许所有的来源
+	allowAll bool
+	origins  map[string]struct{}
+	// wildcards 通配子域名的来源，保存的是 * 前后两部分，例如 https://*.example.com
+	wildcards [][2]string
+	regexps   []*regexp.Regexp
+	// originFunc 自定义的来源判断，例如从数据库中读取
+	originFunc func(origin string) bool
+
+	methods []string
+	// headers 允许的请求头，包含 * 的时候允许预检请求中申请的所有请求头
+	headers      []string
+	allowHeaders bool
+	exposed      []string
+	credentials  bool
+	maxAge       time.Duration
+	// privateNetwork 允许公网的页面访问内网的服务，参考 Private Network Access
+	privateNetwork bool
+}
+
+// AllowOrigins 允许的来源，支持三种写法：
+// * 表示允许所有的来源
+// https://example.com 完全匹配
+// https://*.example.com 匹配所有的子域名，不包括 example.com 自己
+func (m *MiddlewareBuilder) AllowOrigins(origins ...string) *MiddlewareBuilder {
+	for _, origin := range origins {
+		origin = strings.ToLower(origin)
+		if origin == "*" {
+			m.allowAll = true
+			continue
+		}
+		if prefix, suffix, ok := strings.Cut(origin, "*"); ok {
+			m.wildcards = append(m.wildcards, [2]string{prefix, suffix})
+			continue
+		}
+		m.origins[origin] = struct{}{}
+	}
+	return m
+}
+
+// AllowOriginRegexp 用正则表达式匹配来源，表达式不合法的时候直接panic
+// 和其他的规则一样，匹配的是转换成小写之后的来源
+// 注意加上 ^ 和 $，否则 https://evil.com/?https://example.com 之类的来源也能匹配上
+func (m *MiddlewareBuilder) AllowOriginRegexp(exprs ...string) *MiddlewareBuilder {
+	for _, expr := range exprs {
+		m.regexps = append(m.regexps, regexp.MustCompile(expr))
+	}
+	return m
+}
+
+// AllowOriginFunc 自定义判断来源是否允许，和其他的规则是或的关系，传进来的来源已经转换成了小写
+func (m *MiddlewareBuilder) AllowOriginFunc(fn func(origin string) bool) *MiddlewareBuilder {
+	m.originFunc = fn
+	return m
+}
+
+// AllowMethods 允许的请求方法，默认是 GET、HEAD、POST、PUT、PATCH、DELETE
+func (m *MiddlewareBuilder) AllowMethods(methods ...string) *MiddlewareBuilder {
+	m.methods = m.methods[:0]
+	for _, method := range methods {
+		m.methods = append(m.methods, strings.ToUpper(method))
+	}
+	return m
+}
+
+// AllowHeaders 允许的请求头，传 * 的时候允许预检请求中申请的所有请求头
+// 默认是 Origin、Accept、Content-Type、Authorization、X-Requested-With、X-Request-ID
+func (m *MiddlewareBuilder) AllowHeaders(headers ...string) *MiddlewareBuilder {
+	m.headers = m.headers[:0]
+	m.allowHeaders = false
+	for _, header := range headers {
+		if header == "*" {
+			m.allowHeaders = true
+			continue
+		}
+		m.headers = append(m.headers, http.CanonicalHeaderKey(header))
+	}
+	return m
+}
+
+// ExposeHeaders 允许浏览器中的脚本读取的响应头，例如 X-Request-ID
+func (m *MiddlewareBuilder) ExposeHeaders(headers ...string) *MiddlewareBuilder {
+	for _, header := range headers {
+		m.exposed = append(m.exposed, http.CanonicalHeaderKey(header))
+	}
+	return m
+}
+
+// AllowCredentials 允许携带Cookie等凭证
+// 不能和 AllowOrigins("*") 一起使用，否则任何网站都能带着用户的Cookie访问，Build的时候会panic
+func (m *MiddlewareBuilder) AllowCredentials() *MiddlewareBuilder {
+	m.credentials = true
+	return m
+}
+
+// MaxAge 预检请求的结果可以缓存多久，浏览器会有自己的上限
+func (m *MiddlewareBuilder) MaxAge(maxAge time.Duration) *MiddlewareBuilder {
+	m.maxAge = maxAge
+	return m
+}
+
+// AllowPrivateNetwork 预检请求带了 Access-Control-Request-Private-Network 的时候允许访问
+func (m *MiddlewareBuilder) AllowPrivateNetwork() *MiddlewareBuilder {
+	m.privateNetwork = true
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() bilibili_http.MiddlewareHandleFunc {
+	if m.allowAll && m.credentials {
+		panic("web: 允许所有来源的时候不能携带凭证，请指定具体的来源")
+	}
+	methods := strings.Join(m.methods, ", ")
+	exposed := strings.Join(m.exposed, ", ")
+	maxAge := ""
+	if m.maxAge > 0 {
+		maxAge = strconv.Itoa(int(m.maxAge / time.Second))
+	}
+	return func(next bilibili_http.HandleFunc) bilibili_http.HandleFunc {
+		return func(ctx *bilibili_http.Context) {
+			req := ctx.Request()
+			origin := req.Header.Get("Origin")
+			preflight := req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+			// 响应会随着来源变化，告诉缓存不能混用
+			if !m.allowAll {
+				ctx.ResponseHeader().Add("Vary", "Origin")
+			}
+			if preflight {
+				ctx.ResponseHeader().Add("Vary", "Access-Control-Request-Method")
+				ctx.ResponseHeader().Add("Vary", "Access-Control-Request-Headers")
+			}
+			// 不是跨域请求
+			if origin == "" {
+				next(ctx)
+				return
+			}
+			// 来源只转换一次，所有的规则都按照小写匹配，响应头中原样返回
+			allowed := m.allowOrigin(strings.ToLower(origin))
+			if !preflight {
+				// 实际的请求照常处理，没有跨域的响应头浏览器就不会把响应交给脚本
+				if allowed {
+					m.setOrigin(ctx, origin)
+					if exposed != "" {
+						ctx.SetHeader("Access-Control-Expose-Headers", exposed)
+					}
+				}
+				next(ctx)
+				return
+			}
+			if !allowed || !m.allowMethod(req.Header.Get("Access-Control-Request-Method")) {
+				ctx.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			requested := req.Header.Get("Access-Control-Request-Headers")
+			if !m.allowRequestHeaders(requested) {
+				ctx.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			m.setOrigin(ctx, origin)
+			ctx.SetHeader("Access-Control-Allow-Methods", methods)
+			if m.allowHeaders {
+				if requested != "" {
+					ctx.SetHeader("Access-Control-Allow-Headers", requested)
+				}
+			} else if len(m.headers) > 0 {
+				ctx.SetHeader("Access-Control-Allow-Headers", strings.Join(m.headers, ", "))
+			}
+			if maxAge != "" {
+				ctx.SetHeader("Access-Control-Max-Age", maxAge)
+			}
+			if m.privateNetwork && req.Header.Get("Access-Control-Request-Private-Network") == "true" {
+				ctx.SetHeader("Access-Control-Allow-Private-Network", "true")
+			}
+			// 预检请求到这里就结束了，不需要路由上注册OPTIONS
+			ctx.AbortWithStatus(http.StatusNoContent)
+		}
+	}
+}
+
+func (m *MiddlewareBuilder) setOrigin(ctx *bilibili_http.Context, origin string) {
+	if m.allowAll {
+		ctx.SetHeader("Access-Control-Allow-Origin", "*")
+		return
+	}
+	ctx.SetHeader("Access-Control-Allow-Origin", origin)
+	if m.credentials {
+		ctx.SetHeader("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// allowOrigin origin 已经转换成了小写
+func (m *MiddlewareBuilder) allowOrigin(origin string) bool {
+	if m.allowAll {
+		return true
+	}
+	if _, ok := m.origins[origin]; ok {
+		return true
+	}
+	for _, w := range m.wildcards {
+		// 至少要有一个字符匹配 *，否则 https://.example.com 也能通过
+		if len(origin) > len(w[0])+len(w[1]) && strings.HasPrefix(origin, w[0]) && strings.HasSuffix(origin, w[1]) {
+			return true
+		}
+	}
+	for _, re := range m.regexps {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return m.originFunc != nil && m.originFunc(origin)
+}
+
+func (m *MiddlewareBuilder) allowMethod(method string) bool {
+	method = strings.ToUpper(method)
+	for _, allowed := range m.methods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// allowRequestHeaders 预检请求申请的请求头必须都在允许的范围内
+func (m *MiddlewareBuilder) allowRequestHeaders(requested string) bool {
+	if m.allowHeaders || requested == "" {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+		if header == "" {
+			continue
+		}
+		found := false
+		for _, allowed := range m.headers {
+			if allowed == header {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func NewMiddleware() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		origins: map[string]struct{}{},
+		methods: []string{
+			http.MethodGet, http.MethodHead, http.MethodPost,
+			http.MethodPut, http.MethodPatch, http.MethodDelete,
+		},
+		headers: []string{
+			"Origin", "Accept", "Content-Type", "Authorization", "X-Requested-With", "X-Request-Id",
+		},
+	}
+}
@@ -0,0 +1,301 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/borntodie-new/bilibili-http"
+)
+
+// TestMiddleware 测试实际的请求和预检请求
+func TestMiddleware(t *testing.T) {
+	var funcOrigins []string
+	testCases := []struct {
+		name    string
+		builder func() *MiddlewareBuilder
+		method  string
+		url     string
+		header  map[string]string
+
+		wantCode   int
+		wantBody   string
+		wantHeader map[string]string
+		wantVary   []string
+	}{
+		{
+			// 不是跨域请求，照常处理
+			name: "same origin",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOrigins("https://example.com")
+			},
+			method:   http.MethodGet,
+			url:      "/user",
+			wantCode: http.StatusOK,
+			wantBody: "tom",
+			wantVary: []string{"Origin"},
+		},
+		{
+			name: "allowed",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOrigins("https://example.com").ExposeHeaders("x-request-id")
+			},
+			method:   http.MethodGet,
+			url:      "/user",
+			header:   map[string]string{"Origin": "https://example.com"},
+			wantCode: http.StatusOK,
+			wantBody: "tom",
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":   "https://example.com",
+				"Access-Control-Expose-Headers": "X-Request-Id",
+			},
+			wantVary: []string{"Origin"},
+		},
+		{
+			// 视图函数照常执行，没有跨域的响应头浏览器就不会把响应交给脚本
+			name: "not allowed",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOrigins("https://example.com")
+			},
+			method:   http.MethodGet,
+			url:      "/user",
+			header:   map[string]string{"Origin": "https://evil.com"},
+			wantCode: http.StatusOK,
+			wantBody: "tom",
+			wantVary: []string{"Origin"},
+		},
+		{
+			// 允许所有来源的时候响应不随来源变化，不需要 Vary: Origin
+			name: "allow all",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOrigins("*")
+			},
+			method:     http.MethodGet,
+			url:        "/user",
+			header:     map[string]string{"Origin": "https://evil.com"},
+			wantCode:   http.StatusOK,
+			wantBody:   "tom",
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			name: "credentials",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOrigins("https://*.example.com").AllowCredentials()
+			},
+			method:   http.MethodGet,
+			url:      "/user",
+			header:   map[string]string{"Origin": "https://api.example.com"},
+			wantCode: http.StatusOK,
+			wantBody: "tom",
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":      "https://api.example.com",
+				"Access-Control-Allow-Credentials": "true",
+			},
+			wantVary: []string{"Origin"},
+		},
+		{
+			// 通配符至少匹配一个字符，也不包括域名自己
+			name: "wildcard bare domain",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOrigins("https://*.example.com")
+			},
+			method:   http.MethodGet,
+			url:      "/user",
+			header:   map[string]string{"Origin": "https://.example.com"},
+			wantCode: http.StatusOK,
+			wantBody: "tom",
+			wantVary: []string{"Origin"},
+		},
+		{
+			// 所有的规则都按照小写匹配，响应头中原样返回
+			name: "regexp case insensitive",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOriginRegexp(`^https://[a-z]+\.example\.com$`)
+			},
+			method:     http.MethodGet,
+			url:        "/user",
+			header:     map[string]string{"Origin": "HTTPS://API.Example.com"},
+			wantCode:   http.StatusOK,
+			wantBody:   "tom",
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "HTTPS://API.Example.com"},
+			wantVary:   []string{"Origin"},
+		},
+		{
+			name: "origin func",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOriginFunc(func(origin string) bool {
+					funcOrigins = append(funcOrigins, origin)
+					return origin == "https://example.com"
+				})
+			},
+			method:     http.MethodGet,
+			url:        "/user",
+			header:     map[string]string{"Origin": "https://Example.com"},
+			wantCode:   http.StatusOK,
+			wantBody:   "tom",
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "https://Example.com"},
+			wantVary:   []string{"Origin"},
+		},
+		{
+			// 路由上没有注册OPTIONS，预检请求也能通过
+			name: "preflight",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOrigins("https://example.com").AllowCredentials().MaxAge(10 * time.Minute)
+			},
+			method: http.MethodOptions,
+			url:    "/user",
+			header: map[string]string{
+				"Origin":                         "https://example.com",
+				"Access-Control-Request-Method":  "put",
+				"Access-Control-Request-Headers": "content-type, x-request-id",
+			},
+			wantCode: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET, HEAD, POST, PUT, PATCH, DELETE",
+				"Access-Control-Allow-Headers":     "Origin, Accept, Content-Type, Authorization, X-Requested-With, X-Request-Id",
+				"Access-Control-Max-Age":           "600",
+			},
+			wantVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name: "preflight unregistered route",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOrigins("https://example.com").AllowMethods("get", "post")
+			},
+			method: http.MethodOptions,
+			url:    "/order/1",
+			header: map[string]string{
+				"Origin":                        "https://example.com",
+				"Access-Control-Request-Method": "POST",
+			},
+			wantCode: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":  "https://example.com",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "Origin, Accept, Content-Type, Authorization, X-Requested-With, X-Request-Id",
+			},
+			wantVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name: "preflight origin rejected",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOrigins("https://example.com")
+			},
+			method: http.MethodOptions,
+			url:    "/user",
+			header: map[string]string{
+				"Origin":                        "https://evil.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			wantCode: http.StatusForbidden,
+			wantVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name: "preflight method rejected",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOrigins("https://example.com").AllowMethods("GET")
+			},
+			method: http.MethodOptions,
+			url:    "/user",
+			header: map[string]string{
+				"Origin":                        "https://example.com",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			wantCode: http.StatusForbidden,
+			wantVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name: "preflight header rejected",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOrigins("https://example.com")
+			},
+			method: http.MethodOptions,
+			url:    "/user",
+			header: map[string]string{
+				"Origin":                         "https://example.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "x-custom",
+			},
+			wantCode: http.StatusForbidden,
+			wantVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			// 允许所有的请求头的时候原样返回申请的请求头
+			name: "preflight any header",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOrigins("*").AllowHeaders("*").AllowPrivateNetwork()
+			},
+			method: http.MethodOptions,
+			url:    "/user",
+			header: map[string]string{
+				"Origin":                                 "https://example.com",
+				"Access-Control-Request-Method":          "GET",
+				"Access-Control-Request-Headers":         "x-custom",
+				"Access-Control-Request-Private-Network": "true",
+			},
+			wantCode: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":          "*",
+				"Access-Control-Allow-Methods":         "GET, HEAD, POST, PUT, PATCH, DELETE",
+				"Access-Control-Allow-Headers":         "x-custom",
+				"Access-Control-Allow-Private-Network": "true",
+			},
+			wantVary: []string{"Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			// 没有 Access-Control-Request-Method 的OPTIONS请求不是预检请求，交给路由处理
+			name: "options without request method",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().AllowOrigins("https://example.com")
+			},
+			method:     http.MethodOptions,
+			url:        "/user",
+			header:     map[string]string{"Origin": "https://example.com"},
+			wantCode:   http.StatusNotFound,
+			wantBody:   "404 NOT FOUND肯定失败",
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "https://example.com"},
+			wantVary:   []string{"Origin"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := bilibili_http.NewHTTP(bilibili_http.WithLogger(bilibili_http.NopLogger()))
+			h.Use(tc.builder().Build())
+			h.GET("/user", func(ctx *bilibili_http.Context) {
+				ctx.TEXT(http.StatusOK, "tom")
+			})
+			req := httptest.NewRequest(tc.method, tc.url, nil)
+			for key, value := range tc.header {
+				req.Header.Set(key, value)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			for _, key := range []string{
+				"Access-Control-Allow-Origin", "Access-Control-Allow-Credentials", "Access-Control-Allow-Methods",
+				"Access-Control-Allow-Headers", "Access-Control-Expose-Headers", "Access-Control-Max-Age",
+				"Access-Control-Allow-Private-Network",
+			} {
+				assert.Equal(t, tc.wantHeader[key], recorder.Header().Get(key), key)
+			}
+			assert.Equal(t, tc.wantVary, recorder.Header().Values("Vary"))
+		})
+	}
+	assert.Equal(t, []string{"https://example.com"}, funcOrigins)
+}
+
+// TestMiddlewareCredentialsWithAllowAll 允许所有来源的时候不能携带凭证
+func TestMiddlewareCredentialsWithAllowAll(t *testing.T) {
+	assert.PanicsWithValue(t, "web: 允许所有来源的时候不能携带凭证，请指定具体的来源", func() {
+		NewMiddleware().AllowOrigins("https://example.com", "*").AllowCredentials().Build()
+	})
+	assert.NotPanics(t, func() {
+		NewMiddleware().AllowOrigins("https://example.com").AllowCredentials().Build()
+	})
+}
@@ -0,0 +1,72 @@
+package errorhandler
+
+import (
+	"net/http"
+
+	"github.com/borntodie-new/bilibili-http"
+)
+
+// MiddlewareBuilder 统一渲染请求过程中通过 ctx.Error 积累下来的错误
+// 视图函数或者后面的中间件已经写了响应体的话，就不再处理
+type MiddlewareBuilder struct {
+	// showPrivate 是否展示 ErrorTypePrivate 类型的错误信息，一般只在开发环境打开
+	showPrivate bool
+	// renderFunc 自定义错误的响应方式
+	renderFunc func(ctx *bilibili_http.Context, code int, errs bilibili_http.ErrorList)
+}
+
+// ShowPrivate 设置是否把内部错误的信息展示给客户端
+func (m *MiddlewareBuilder) ShowPrivate(show bool) *MiddlewareBuilder {
+	m.showPrivate = show
+	return m
+}
+
+// RenderFunc 自定义错误的响应方式
+func (m *MiddlewareBuilder) RenderFunc(fn func(ctx *bilibili_http.Context, code int, errs bilibili_http.ErrorList)) *MiddlewareBuilder {
+	m.renderFunc = fn
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() bilibili_http.MiddlewareHandleFunc {
+	return func(next bilibili_http.HandleFunc) bilibili_http.HandleFunc {
+		return func(ctx *bilibili_http.Context) {
+			next(ctx)
+			errs := ctx.Errors()
+			if len(errs) == 0 || len(ctx.ResponseData()) != 0 {
+				return
+			}
+			// 之前通过AbortWithStatus之类的方法设置过错误状态码的，就沿用它
+			code := ctx.StatusCode()
+			if code < http.StatusBadRequest {
+				code = http.StatusInternalServerError
+				if len(errs.ByType(bilibili_http.ErrorTypeBind)) == len(errs) {
+					code = http.StatusBadRequest
+				}
+			}
+			m.renderFunc(ctx, code, errs)
+		}
+	}
+}
+
+// render 默认的响应方式
+// {"errors": [{"error": "xxx", "meta": ...}]}
+func (m *MiddlewareBuilder) render(ctx *bilibili_http.Context, code int, errs bilibili_http.ErrorList) {
+	res := make([]bilibili_http.H, 0, len(errs))
+	for _, err := range errs {
+		item := bilibili_http.H{"error": err.Error()}
+		if err.IsType(bilibili_http.ErrorTypePrivate) && !m.showPrivate {
+			// 内部错误只告诉客户端出错了，具体原因不能暴露
+			item["error"] = http.StatusText(code)
+		} else if err.Meta != nil {
+			item["meta"] = err.Meta
+		}
+		res = append(res, item)
+	}
+	ctx.JSON(code, bilibili_http.H{"errors": res})
+}
+
+func NewMiddleware() *MiddlewareBuilder {
+	m := &MiddlewareBuilder{}
+	m.renderFunc = m.render
+	return m
+}
@@ -0,0 +1,141 @@
+package errorhandler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/borntodie-new/bilibili-http"
+)
+
+// TestMiddleware 测试错误的状态码、内部错误的隐藏以及跳过已经响应的请求
+func TestMiddleware(t *testing.T) {
+	testCases := []struct {
+		name    string
+		builder func() *MiddlewareBuilder
+		handler bilibili_http.HandleFunc
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			// 只有解析失败的错误时响应400
+			name:    "bind",
+			builder: NewMiddleware,
+			handler: func(ctx *bilibili_http.Context) {
+				ctx.Error(errors.New("age必须是数字")).SetType(bilibili_http.ErrorTypeBind)
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"errors":[{"error":"age必须是数字"}]}`,
+		},
+		{
+			// 混有其他错误时响应500，内部错误只展示状态码对应的信息
+			name:    "bind and private",
+			builder: NewMiddleware,
+			handler: func(ctx *bilibili_http.Context) {
+				ctx.Error(errors.New("age必须是数字")).SetType(bilibili_http.ErrorTypeBind)
+				ctx.Error(errors.New("db down"))
+			},
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"errors":[{"error":"age必须是数字"},{"error":"Internal Server Error"}]}`,
+		},
+		{
+			name:    "private",
+			builder: NewMiddleware,
+			handler: func(ctx *bilibili_http.Context) {
+				ctx.Error(errors.New("db down")).SetMeta("user")
+			},
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"errors":[{"error":"Internal Server Error"}]}`,
+		},
+		{
+			name: "show private",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().ShowPrivate(true)
+			},
+			handler: func(ctx *bilibili_http.Context) {
+				ctx.Error(errors.New("db down")).SetMeta("user")
+			},
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"errors":[{"error":"db down","meta":"user"}]}`,
+		},
+		{
+			// 已经设置过的错误状态码沿用下来
+			name:    "keep status",
+			builder: NewMiddleware,
+			handler: func(ctx *bilibili_http.Context) {
+				ctx.AbortWithError(http.StatusForbidden, errors.New("没有权限")).
+					SetType(bilibili_http.ErrorTypePublic).SetMeta("token")
+			},
+			wantCode: http.StatusForbidden,
+			wantBody: `{"errors":[{"error":"没有权限","meta":"token"}]}`,
+		},
+		{
+			name:    "keep 5xx status",
+			builder: NewMiddleware,
+			handler: func(ctx *bilibili_http.Context) {
+				ctx.AbortWithError(http.StatusServiceUnavailable, errors.New("稍后再试")).SetType(bilibili_http.ErrorTypeBind)
+			},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"errors":[{"error":"稍后再试"}]}`,
+		},
+		{
+			// 视图函数已经响应了，不再处理
+			name:    "body written",
+			builder: NewMiddleware,
+			handler: func(ctx *bilibili_http.Context) {
+				ctx.Error(errors.New("db down"))
+				ctx.TEXT(http.StatusOK, "tom")
+			},
+			wantCode: http.StatusOK,
+			wantBody: "tom",
+		},
+		{
+			// DefaultErrorHandler 已经把HTTPError渲染出来了
+			name:    "http error",
+			builder: NewMiddleware,
+			handler: bilibili_http.E(func(ctx *bilibili_http.Context) error {
+				return bilibili_http.NewHTTPError(http.StatusNotFound, "用户不存在")
+			}),
+			wantCode: http.StatusNotFound,
+			wantBody: `{"code":404,"msg":"用户不存在"}`,
+		},
+		{
+			// 没有错误的时候不输出任何内容
+			name:    "no errors",
+			builder: NewMiddleware,
+			handler: func(ctx *bilibili_http.Context) {
+				ctx.SetStatusCode(http.StatusForbidden)
+			},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name: "custom render",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().RenderFunc(func(ctx *bilibili_http.Context, code int, errs bilibili_http.ErrorList) {
+					ctx.TEXT(code, errs.String())
+				})
+			},
+			handler: func(ctx *bilibili_http.Context) {
+				ctx.Error(errors.New("db down"))
+			},
+			wantCode: http.StatusInternalServerError,
+			wantBody: "Error #01: db down",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := bilibili_http.NewHTTP(bilibili_http.WithLogger(bilibili_http.NopLogger()))
+			h.Use(tc.builder().Build())
+			h.GET("/user", tc.handler)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user", nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, strings.TrimSpace(recorder.Body.String()))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package etag
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/borntodie-new/bilibili-http"
+)
+
+// MiddlewareBuilder 根据响应体计算ETag
+// 计算好ETag之后交给 Context.ServeContent 处理条件请求和Range请求
+// 所以 If-None-Match、If-Match、If-Range、Range 这些请求头都能得到正确的处理
+type MiddlewareBuilder struct {
+	// weak 是否生成弱ETag：W/"..."
+	// 弱ETag表示内容语义上相同，不能用于Range请求的If-Range判断
+	weak bool
+}
+
+// Weak 设置是否生成弱ETag
+func (m *MiddlewareBuilder) Weak(weak bool) *MiddlewareBuilder {
+	m.weak = weak
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() bilibili_http.MiddlewareHandleFunc {
+	return func(next bilibili_http.HandleFunc) bilibili_http.HandleFunc {
+		return func(ctx *bilibili_http.Context) {
+			next(ctx)
+			// 文件这类流式响应已经直接写出去了，条件请求也已经由 http.ServeContent 处理过了
+			if ctx.Committed() {
+				return
+			}
+			method := ctx.Request().Method
+			if method != http.MethodGet && method != http.MethodHead {
+				return
+			}
+			// 只处理完整的正常响应，已经是206、304这类的响应说明后面已经处理过了
+			if ctx.StatusCode() != http.StatusOK {
+				return
+			}
+			// HEAD请求一般没有响应体，例如 ServeContent 只设置了Content-Length
+			// 这时候算出来的是空响应体的ETag，还会把Content-Length改成0，保留视图函数自己的结果
+			if method == http.MethodHead && len(ctx.ResponseData()) == 0 {
+				return
+			}
+			header := ctx.ResponseHeader()
+			if header.Get("Etag") == "" {
+				header.Set("Etag", m.generate(ctx.ResponseData()))
+			}
+			var modtime time.Time
+			if lastModified := header.Get("Last-Modified"); lastModified != "" {
+				modtime, _ = http.ParseTime(lastModified)
+			}
+			ctx.ServeContent("", modtime, bytes.NewReader(ctx.ResponseData()))
+		}
+	}
+}
+
+// generate 根据响应体生成ETag
+func (m *MiddlewareBuilder) generate(data []byte) string {
+	sum := sha1.Sum(data)
+	if m.weak {
+		return fmt.Sprintf(`W/"%x-%s"`, len(data), hex.EncodeToString(sum[:8]))
+	}
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:]))
+}
+
+func NewMiddleware() *MiddlewareBuilder {
+	return &MiddlewareBuilder{}
+}
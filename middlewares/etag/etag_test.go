@@ -0,0 +1,180 @@
+package etag
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/borntodie-new/bilibili-http"
+)
+
+const content = "0123456789abcdefghij"
+
+func strongETag(data string) string {
+	sum := sha1.Sum([]byte(data))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// TestMiddleware 测试生成ETag以及条件请求和Range请求
+func TestMiddleware(t *testing.T) {
+	text := func(ctx *bilibili_http.Context) {
+		ctx.TEXT(http.StatusOK, content)
+	}
+	// serveContent 和 ServeContent 一样，HEAD请求只设置Content-Length，没有响应体
+	serveContent := func(ctx *bilibili_http.Context) {
+		ctx.ServeContent("data.txt", time.Time{}, strings.NewReader(content))
+	}
+	testCases := []struct {
+		name       string
+		builder    *MiddlewareBuilder
+		method     string
+		header     map[string]string
+		handleFunc bilibili_http.HandleFunc
+
+		wantCode          int
+		wantBody          string
+		wantETag          string
+		wantContentLength string
+	}{
+		{
+			name:              "get",
+			method:            http.MethodGet,
+			handleFunc:        text,
+			wantCode:          http.StatusOK,
+			wantBody:          content,
+			wantETag:          strongETag(content),
+			wantContentLength: "20",
+		},
+		{
+			// 和GET请求的ETag、Content-Length一致，只是没有响应体
+			name:              "head",
+			method:            http.MethodHead,
+			handleFunc:        text,
+			wantCode:          http.StatusOK,
+			wantETag:          strongETag(content),
+			wantContentLength: "20",
+		},
+		{
+			// 不能算出空响应体的ETag，也不能把Content-Length改成0
+			name:              "head without body",
+			method:            http.MethodHead,
+			handleFunc:        serveContent,
+			wantCode:          http.StatusOK,
+			wantContentLength: "20",
+		},
+		{
+			name:       "not modified",
+			method:     http.MethodGet,
+			header:     map[string]string{"If-None-Match": strongETag(content)},
+			handleFunc: text,
+			wantCode:   http.StatusNotModified,
+			wantETag:   strongETag(content),
+		},
+		{
+			name:              "range",
+			method:            http.MethodGet,
+			header:            map[string]string{"Range": "bytes=0-4"},
+			handleFunc:        text,
+			wantCode:          http.StatusPartialContent,
+			wantBody:          "01234",
+			wantETag:          strongETag(content),
+			wantContentLength: "5",
+		},
+		{
+			// ETag变了，If-Range不满足，返回完整的内容
+			name:              "if range mismatch",
+			method:            http.MethodGet,
+			header:            map[string]string{"Range": "bytes=0-4", "If-Range": `"old"`},
+			handleFunc:        text,
+			wantCode:          http.StatusOK,
+			wantBody:          content,
+			wantETag:          strongETag(content),
+			wantContentLength: "20",
+		},
+		{
+			name:              "weak",
+			builder:           NewMiddleware().Weak(true),
+			method:            http.MethodGet,
+			handleFunc:        text,
+			wantCode:          http.StatusOK,
+			wantBody:          content,
+			wantETag:          `W/"14-` + strings.Trim(strongETag(content), `"`)[:16] + `"`,
+			wantContentLength: "20",
+		},
+		{
+			name:   "keep etag",
+			method: http.MethodGet,
+			handleFunc: func(ctx *bilibili_http.Context) {
+				ctx.SetHeader("Etag", `"v1"`)
+				ctx.TEXT(http.StatusOK, content)
+			},
+			wantCode:          http.StatusOK,
+			wantBody:          content,
+			wantETag:          `"v1"`,
+			wantContentLength: "20",
+		},
+		{
+			name:   "error status",
+			method: http.MethodGet,
+			handleFunc: func(ctx *bilibili_http.Context) {
+				ctx.TEXT(http.StatusNotFound, "not found")
+			},
+			wantCode:          http.StatusNotFound,
+			wantBody:          "not found",
+			wantContentLength: "9",
+		},
+		{
+			name:              "post",
+			method:            http.MethodPost,
+			handleFunc:        text,
+			wantCode:          http.StatusOK,
+			wantBody:          content,
+			wantContentLength: "20",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := tc.builder
+			if builder == nil {
+				builder = NewMiddleware()
+			}
+			req := httptest.NewRequest(tc.method, "/data", nil)
+			for key, value := range tc.header {
+				req.Header.Set(key, value)
+			}
+			recorder := httptest.NewRecorder()
+			ctx := bilibili_http.NewContext(recorder, req)
+			builder.Build()(tc.handleFunc)(ctx)
+			ctx.Commit()
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantETag, recorder.Header().Get("Etag"))
+			assert.Equal(t, tc.wantContentLength, recorder.Header().Get("Content-Length"))
+		})
+	}
+}
+
+// TestMiddlewareStatic 和静态文件服务一起使用，GET和HEAD请求的响应头要一致
+func TestMiddlewareStatic(t *testing.T) {
+	h := bilibili_http.NewHTTP(bilibili_http.WithLogger(bilibili_http.NopLogger()))
+	h.Use(NewMiddleware().Build())
+	h.StaticFS("/static", fstest.MapFS{"data.txt": {Data: []byte(content)}})
+	headers := make([]http.Header, 0, 2)
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest(method, "/static/data.txt", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		headers = append(headers, recorder.Header())
+	}
+	assert.Equal(t, "20", headers[0].Get("Content-Length"))
+	assert.Equal(t, headers[0].Get("Content-Length"), headers[1].Get("Content-Length"))
+	assert.Equal(t, headers[0].Get("Etag"), headers[1].Get("Etag"))
+	assert.Equal(t, headers[0].Get("Last-Modified"), headers[1].Get("Last-Modified"))
+}
@@ -1,19 +1,58 @@
 package flush
 
-import "github.com/borntodie-new/bilibili-http"
+import (
+	"github.com/borntodie-new/bilibili-http"
+)
 
+// Transformer 在响应写入之前修改响应，参考 bilibili_http.ResponseTransformer
+// 流式响应在视图函数中就已经写出去了，不会经过Transformer
+type Transformer = bilibili_http.ResponseTransformer
+
+// MiddlewareBuilder 负责把缓冲在上下文中的响应写回去
+// 框架内置的flush就是 NewMiddleware().Build()，需要定制的时候用它替换内置的flush和recovery：
+// bilibili_http.NewHTTP(bilibili_http.WithDefaultMiddleware(flush.NewMiddleware().Transform(envelope).Recovery(rec).Middlewares()...))
 type MiddlewareBuilder struct {
+	transformers []Transformer
+	// recovery 和flush一起注册的recovery中间件
+	recovery bilibili_http.MiddlewareHandleFunc
+	// recoveryOutside recovery是否在flush的外层
+	recoveryOutside bool
 }
 
-func (m *MiddlewareBuilder) Build() bilibili_http.MiddlewareHandleFunc {
-	return func(next bilibili_http.HandleFunc) bilibili_http.HandleFunc {
-		return func(ctx *bilibili_http.Context) {
-			defer func() {
+// Transform 注册响应转换函数，按照注册的顺序调用
+func (m *MiddlewareBuilder) Transform(fn Transformer) *MiddlewareBuilder {
+	m.transformers = append(m.transformers, fn)
+	return m
+}
 
-			}()
-			next(ctx)
-		}
+// Recovery 设置和flush一起使用的recovery中间件，通过 Middlewares 获取排好顺序的中间件
+// 默认flush在外层，recovery生成的500响应同样会经过Transformer
+func (m *MiddlewareBuilder) Recovery(recovery bilibili_http.MiddlewareHandleFunc) *MiddlewareBuilder {
+	m.recovery = recovery
+	return m
+}
+
+// RecoveryOutside recovery放在flush的外层
+// 视图函数panic的时候flush不会执行，recovery生成的响应原样由ServeHTTP写回去，不经过Transformer
+func (m *MiddlewareBuilder) RecoveryOutside() *MiddlewareBuilder {
+	m.recoveryOutside = true
+	return m
+}
+
+// Middlewares 按照配置的顺序返回flush和recovery中间件，外层的在前
+func (m *MiddlewareBuilder) Middlewares() []bilibili_http.MiddlewareHandleFunc {
+	if m.recovery == nil {
+		return []bilibili_http.MiddlewareHandleFunc{m.Build()}
+	}
+	if m.recoveryOutside {
+		return []bilibili_http.MiddlewareHandleFunc{m.recovery, m.Build()}
 	}
+	return []bilibili_http.MiddlewareHandleFunc{m.Build(), m.recovery}
+}
+
+// Build 和框架内置的flush是同一个实现，只是多了Transformer
+func (m *MiddlewareBuilder) Build() bilibili_http.MiddlewareHandleFunc {
+	return bilibili_http.Flush(m.transformers...)
 }
 
 func NewMiddleware() *MiddlewareBuilder {
@@ -0,0 +1,126 @@
+package flush
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/borntodie-new/bilibili-http"
+)
+
+// envelope 把JSON响应统一包装成 {"code":0,"data":...}
+func envelope(ctx *bilibili_http.Context) {
+	if ctx.ResponseHeader().Get("Content-Type") != "application/json; charset=utf-8" {
+		return
+	}
+	code := 0
+	if ctx.StatusCode() >= http.StatusBadRequest {
+		code = ctx.StatusCode()
+	}
+	data, _ := json.Marshal(map[string]any{"code": code, "data": json.RawMessage(ctx.ResponseData())})
+	ctx.SetData(data)
+}
+
+// recovery 测试用的recovery，响应JSON
+func recovery(next bilibili_http.HandleFunc) bilibili_http.HandleFunc {
+	return func(ctx *bilibili_http.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				ctx.JSON(http.StatusInternalServerError, err)
+			}
+		}()
+		next(ctx)
+	}
+}
+
+// TestMiddleware 测试响应转换以及和recovery的顺序
+func TestMiddleware(t *testing.T) {
+	testCases := []struct {
+		name    string
+		builder *MiddlewareBuilder
+		url     string
+
+		wantCode          int
+		wantBody          string
+		wantContentLength string
+	}{
+		{
+			name:              "no transformer",
+			builder:           NewMiddleware(),
+			url:               "/user",
+			wantCode:          http.StatusOK,
+			wantBody:          `{"name":"tom"}`,
+			wantContentLength: "14",
+		},
+		{
+			// 按照注册的顺序调用，Content-Length按照转换之后的响应体计算
+			name: "transform",
+			builder: NewMiddleware().Transform(envelope).Transform(func(ctx *bilibili_http.Context) {
+				ctx.SetData(append(ctx.ResponseData(), '\n'))
+			}),
+			url:               "/user",
+			wantCode:          http.StatusOK,
+			wantBody:          `{"code":0,"data":{"name":"tom"}}` + "\n",
+			wantContentLength: "33",
+		},
+		{
+			// 流式响应已经写出去了，不会再转换
+			name:     "stream",
+			builder:  NewMiddleware().Transform(envelope),
+			url:      "/stream",
+			wantCode: http.StatusOK,
+			wantBody: `{"name":"tom"}`,
+		},
+		{
+			// 默认flush在外层，recovery生成的响应同样会经过转换
+			name:              "recovery inside",
+			builder:           NewMiddleware().Transform(envelope).Recovery(recovery),
+			url:               "/panic",
+			wantCode:          http.StatusInternalServerError,
+			wantBody:          `{"code":500,"data":"db down"}`,
+			wantContentLength: "29",
+		},
+		{
+			name:              "recovery outside",
+			builder:           NewMiddleware().Transform(envelope).Recovery(recovery).RecoveryOutside(),
+			url:               "/panic",
+			wantCode:          http.StatusInternalServerError,
+			wantBody:          `"db down"`,
+			wantContentLength: "9",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := bilibili_http.NewHTTP(bilibili_http.WithDefaultMiddleware(tc.builder.Middlewares()...))
+			h.GET("/user", func(ctx *bilibili_http.Context) {
+				ctx.JSON(http.StatusOK, map[string]string{"name": "tom"})
+			})
+			h.GET("/stream", func(ctx *bilibili_http.Context) {
+				ctx.SetHeader("Content-Type", "application/json; charset=utf-8")
+				ctx.Stream(func(w io.Writer) bool {
+					_, _ = io.WriteString(w, `{"name":"tom"}`)
+					return false
+				})
+			})
+			h.GET("/panic", func(ctx *bilibili_http.Context) {
+				panic("db down")
+			})
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantContentLength, recorder.Header().Get("Content-Length"))
+		})
+	}
+}
+
+// TestMiddlewareMiddlewares 测试返回的中间件数量，没有设置recovery的时候只有flush
+func TestMiddlewareMiddlewares(t *testing.T) {
+	assert.Len(t, NewMiddleware().Middlewares(), 1)
+	assert.Len(t, NewMiddleware().Recovery(recovery).Middlewares(), 2)
+	assert.Len(t, NewMiddleware().Recovery(recovery).RecoveryOutside().Middlewares(), 2)
+}
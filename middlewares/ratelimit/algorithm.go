@@ -0,0 +1,184 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// State 一个key的限流状态，所有的算法共用这个结构，Store只需要原样保存
+type State struct {
+	// Tokens 令牌桶中剩余的令牌数
+	Tokens float64
+	// Last 令牌桶上一次补充令牌的时间，或者当前窗口的开始时间
+	Last time.Time
+	// Count 当前窗口的请求数
+	Count int64
+	// PrevCount 滑动窗口中上一个窗口的请求数
+	PrevCount int64
+}
+
+// Result 一次限流判断的结果
+type Result struct {
+	// Allowed 是否放行
+	Allowed bool
+	// Limit 一个周期内允许的请求数
+	Limit int
+	// Remaining 当前还剩下的请求数
+	Remaining int
+	// Reset 多久之后配额完全恢复
+	Reset time.Duration
+	// RetryAfter 被拒绝的时候多久之后可以重试
+	RetryAfter time.Duration
+}
+
+// Algorithm 限流算法，根据key当前的状态判断是否放行，同时更新状态
+type Algorithm interface {
+	Take(state *State, now time.Time) Result
+	// TTL 多久没有访问之后状态就没有意义了，Store可以清理掉
+	TTL() time.Duration
+}
+
+// tokenBucket 令牌桶，允许一定程度的突发流量
+type tokenBucket struct {
+	// rate 每纳秒补充的令牌数
+	rate  float64
+	burst int
+}
+
+// TokenBucket 每per时间补充limit个令牌，桶中最多存放burst个令牌
+// 例如 TokenBucket(10, time.Second, 20) 表示平均每秒10个请求，最多一次性处理20个
+func TokenBucket(limit int, per time.Duration, burst int) Algorithm {
+	if limit <= 0 || per <= 0 || burst <= 0 {
+		panic("web: 令牌桶的参数必须大于0")
+	}
+	return &tokenBucket{
+		rate:  float64(limit) / float64(per),
+		burst: burst,
+	}
+}
+
+func (t *tokenBucket) Take(state *State, now time.Time) Result {
+	burst := float64(t.burst)
+	if state.Last.IsZero() {
+		state.Tokens = burst
+	} else if elapsed := now.Sub(state.Last); elapsed > 0 {
+		state.Tokens = math.Min(burst, state.Tokens+float64(elapsed)*t.rate)
+	}
+	state.Last = now
+	res := Result{Limit: t.burst}
+	if state.Tokens >= 1 {
+		state.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = t.duration(1 - state.Tokens)
+	}
+	res.Remaining = int(state.Tokens)
+	res.Reset = t.duration(burst - state.Tokens)
+	return res
+}
+
+// duration 补充n个令牌需要的时间
+func (t *tokenBucket) duration(n float64) time.Duration {
+	return time.Duration(math.Ceil(n / t.rate))
+}
+
+func (t *tokenBucket) TTL() time.Duration {
+	return t.duration(float64(t.burst))
+}
+
+// fixedWindow 固定窗口，实现简单，但是窗口交界的地方可能通过两倍的请求
+type fixedWindow struct {
+	limit  int
+	window time.Duration
+}
+
+// FixedWindow 每个window时间内最多limit个请求，窗口按照自然时间对齐
+func FixedWindow(limit int, window time.Duration) Algorithm {
+	if limit <= 0 || window <= 0 {
+		panic("web: 固定窗口的参数必须大于0")
+	}
+	return &fixedWindow{limit: limit, window: window}
+}
+
+func (f *fixedWindow) Take(state *State, now time.Time) Result {
+	start := now.Truncate(f.window)
+	if !state.Last.Equal(start) {
+		state.Last = start
+		state.Count = 0
+	}
+	res := Result{Limit: f.limit, Reset: start.Add(f.window).Sub(now)}
+	if state.Count < int64(f.limit) {
+		state.Count++
+		res.Allowed = true
+	} else {
+		res.RetryAfter = res.Reset
+	}
+	res.Remaining = f.limit - int(state.Count)
+	return res
+}
+
+func (f *fixedWindow) TTL() time.Duration {
+	return f.window
+}
+
+// slidingWindow 滑动窗口，用上一个窗口的请求数按照时间加权估算，避免了固定窗口交界处的突发
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+}
+
+// SlidingWindow 任意window时间内最多大约limit个请求
+func SlidingWindow(limit int, window time.Duration) Algorithm {
+	if limit <= 0 || window <= 0 {
+		panic("web: 滑动窗口的参数必须大于0")
+	}
+	return &slidingWindow{limit: limit, window: window}
+}
+
+func (s *slidingWindow) Take(state *State, now time.Time) Result {
+	start := now.Truncate(s.window)
+	if !state.Last.Equal(start) {
+		// 紧挨着的上一个窗口才有参考价值，再早的直接丢弃
+		if state.Last.Equal(start.Add(-s.window)) {
+			state.PrevCount = state.Count
+		} else {
+			state.PrevCount = 0
+		}
+		state.Count = 0
+		state.Last = start
+	}
+	elapsed := now.Sub(start)
+	// 上一个窗口还有多少比例落在当前的滑动窗口中
+	weight := 1 - float64(elapsed)/float64(s.window)
+	estimated := float64(state.PrevCount)*weight + float64(state.Count)
+	res := Result{Limit: s.limit, Reset: s.window - elapsed}
+	limit := float64(s.limit)
+	if estimated+1 <= limit {
+		state.Count++
+		estimated++
+		res.Allowed = true
+	} else {
+		res.RetryAfter = s.retryAfter(state, elapsed)
+	}
+	res.Remaining = int(math.Max(0, math.Floor(limit-estimated)))
+	return res
+}
+
+// retryAfter 上一个窗口的权重降到多少才能再放行一个请求
+func (s *slidingWindow) retryAfter(state *State, elapsed time.Duration) time.Duration {
+	free := float64(s.limit) - float64(state.Count) - 1
+	if free < 0 || state.PrevCount == 0 {
+		// 当前窗口自己就已经满了，只能等下一个窗口
+		return s.window - elapsed
+	}
+	weight := free / float64(state.PrevCount)
+	wait := time.Duration(math.Ceil((1-weight)*float64(s.window))) - elapsed
+	if wait <= 0 {
+		return time.Millisecond
+	}
+	return wait
+}
+
+func (s *slidingWindow) TTL() time.Duration {
+	return 2 * s.window
+}
@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// step 某个时间点的一次请求以及期望的结果
+type step struct {
+	offset time.Duration
+	want   Result
+}
+
+// TestAlgorithm 测试限流算法，同一个用例中的请求共用一个状态
+func TestAlgorithm(t *testing.T) {
+	// 对齐到分钟，方便计算窗口
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name      string
+		algorithm Algorithm
+		wantTTL   time.Duration
+		steps     []step
+	}{
+		{
+			name:      "token bucket",
+			algorithm: TokenBucket(1, time.Second, 2),
+			wantTTL:   2 * time.Second,
+			steps: []step{
+				// 一开始桶是满的，可以突发burst个请求
+				{offset: 0, want: Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}},
+				{offset: 0, want: Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second}},
+				{offset: 0, want: Result{Limit: 2, Remaining: 0, Reset: 2 * time.Second, RetryAfter: time.Second}},
+				// 补充了半个令牌，还要再等半秒
+				{offset: 500 * time.Millisecond, want: Result{Limit: 2, Remaining: 0, Reset: 1500 * time.Millisecond, RetryAfter: 500 * time.Millisecond}},
+				{offset: 1250 * time.Millisecond, want: Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 1750 * time.Millisecond}},
+				// 很久没有请求，令牌最多补满burst个
+				{offset: time.Minute, want: Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}},
+			},
+		},
+		{
+			name:      "fixed window",
+			algorithm: FixedWindow(2, time.Minute),
+			wantTTL:   time.Minute,
+			steps: []step{
+				{offset: 10 * time.Second, want: Result{Allowed: true, Limit: 2, Remaining: 1, Reset: 50 * time.Second}},
+				{offset: 20 * time.Second, want: Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 40 * time.Second}},
+				// 被拒绝的请求要等到下一个窗口
+				{offset: 30 * time.Second, want: Result{Limit: 2, Remaining: 0, Reset: 30 * time.Second, RetryAfter: 30 * time.Second}},
+				// 新的窗口重新计数
+				{offset: time.Minute, want: Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Minute}},
+				{offset: 3*time.Minute + 30*time.Second, want: Result{Allowed: true, Limit: 2, Remaining: 1, Reset: 30 * time.Second}},
+			},
+		},
+		{
+			name:      "sliding window",
+			algorithm: SlidingWindow(4, time.Minute),
+			wantTTL:   2 * time.Minute,
+			steps: []step{
+				{offset: 0, want: Result{Allowed: true, Limit: 4, Remaining: 3, Reset: time.Minute}},
+				{offset: 10 * time.Second, want: Result{Allowed: true, Limit: 4, Remaining: 2, Reset: 50 * time.Second}},
+				{offset: 20 * time.Second, want: Result{Allowed: true, Limit: 4, Remaining: 1, Reset: 40 * time.Second}},
+				{offset: 30 * time.Second, want: Result{Allowed: true, Limit: 4, Remaining: 0, Reset: 30 * time.Second}},
+				// 当前窗口自己就满了，只能等下一个窗口
+				{offset: 40 * time.Second, want: Result{Limit: 4, Remaining: 0, Reset: 20 * time.Second, RetryAfter: 20 * time.Second}},
+				// 新的窗口开始的时候上一个窗口的权重是1，要等它降到3/4
+				{offset: time.Minute, want: Result{Limit: 4, Remaining: 0, Reset: time.Minute, RetryAfter: 15 * time.Second}},
+				{offset: time.Minute + 15*time.Second, want: Result{Allowed: true, Limit: 4, Remaining: 0, Reset: 45 * time.Second}},
+				// 4*2/3+1+1 超过了4，上一个窗口的权重降到1/2的时候才能放行
+				{offset: time.Minute + 20*time.Second, want: Result{Limit: 4, Remaining: 0, Reset: 40 * time.Second, RetryAfter: 10 * time.Second}},
+				{offset: time.Minute + 30*time.Second, want: Result{Allowed: true, Limit: 4, Remaining: 0, Reset: 30 * time.Second}},
+				// 上一个窗口不是紧挨着的，不再参考
+				{offset: 3*time.Minute + 20*time.Second, want: Result{Allowed: true, Limit: 4, Remaining: 3, Reset: 40 * time.Second}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantTTL, tc.algorithm.TTL())
+			state := &State{}
+			for i, s := range tc.steps {
+				res := tc.algorithm.Take(state, start.Add(s.offset))
+				assert.Equal(t, s.want.Allowed, res.Allowed, "step %d", i)
+				assert.Equal(t, s.want.Limit, res.Limit, "step %d", i)
+				assert.Equal(t, s.want.Remaining, res.Remaining, "step %d", i)
+				// 令牌桶用浮点数计算，允许1微秒的误差
+				assert.InDelta(t, s.want.Reset, res.Reset, float64(time.Microsecond), "step %d", i)
+				assert.InDelta(t, s.want.RetryAfter, res.RetryAfter, float64(time.Microsecond), "step %d", i)
+			}
+		})
+	}
+}
+
+// TestAlgorithmInvalid 参数不合法的时候直接panic
+func TestAlgorithmInvalid(t *testing.T) {
+	assert.PanicsWithValue(t, "web: 令牌桶的参数必须大于0", func() {
+		TokenBucket(0, time.Second, 1)
+	})
+	assert.PanicsWithValue(t, "web: 令牌桶的参数必须大于0", func() {
+		TokenBucket(1, time.Second, 0)
+	})
+	assert.PanicsWithValue(t, "web: 固定窗口的参数必须大于0", func() {
+		FixedWindow(1, 0)
+	})
+	assert.PanicsWithValue(t, "web: 滑动窗口的参数必须大于0", func() {
+		SlidingWindow(0, time.Minute)
+	})
+}
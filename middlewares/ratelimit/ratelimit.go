@@ -0,0 +1,169 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/borntodie-new/bilibili-http"
+)
+
+// MiddlewareBuilder 限流中间件
+// 默认按照客户端IP限流，状态保存在内存中，超过限制的请求响应429：
+// h.Use(ratelimit.NewMiddleware(ratelimit.TokenBucket(10, time.Second, 20)).Build())
+// 客户端IP的获取方式参考 bilibili_http.WithTrustedProxies，部署在代理后面的时候一定要设置
+type MiddlewareBuilder struct {
+	algorithm Algorithm
+	store     Store
+	// prefix 多个限流中间件共用一个Store的时候用来区分
+	prefix  string
+	keyFunc func(ctx *bilibili_http.Context) string
+	// limitedFunc 超过限制之后的响应
+	limitedFunc func(ctx *bilibili_http.Context, res Result)
+	// failClosed Store出错的时候拒绝请求，默认放行
+	failClosed bool
+	// headers 是否输出 RateLimit-* 响应头
+	headers bool
+	// now 获取当前时间，测试中替换掉
+	now func() time.Time
+}
+
+// Store 使用外部的存储，多个实例共享限流状态，默认是 NewMemoryStore 创建的内存存储
+func (m *MiddlewareBuilder) Store(store Store) *MiddlewareBuilder {
+	m.store = store
+	return m
+}
+
+// Prefix 保存到Store中的key的前缀，默认是 ratelimit:
+func (m *MiddlewareBuilder) Prefix(prefix string) *MiddlewareBuilder {
+	m.prefix = prefix
+	return m
+}
+
+// KeyFunc 自定义限流的维度，例如按照登录的用户限流，返回空字符串的请求不限流
+func (m *MiddlewareBuilder) KeyFunc(fn func(ctx *bilibili_http.Context) string) *MiddlewareBuilder {
+	m.keyFunc = fn
+	return m
+}
+
+// KeyByIP 按照客户端IP限流，这是默认的方式
+func (m *MiddlewareBuilder) KeyByIP() *MiddlewareBuilder {
+	return m.KeyFunc(func(ctx *bilibili_http.Context) string {
+		return "ip:" + ctx.ClientIP()
+	})
+}
+
+// KeyByRoute 按照路由限流，例如 /user/:id 的所有请求共用一个配额，保护下游的接口
+// 没有匹配到路由的请求不限流
+func (m *MiddlewareBuilder) KeyByRoute() *MiddlewareBuilder {
+	return m.KeyFunc(func(ctx *bilibili_http.Context) string {
+		if ctx.Route() == "" {
+			return ""
+		}
+		return "route:" + ctx.Method + " " + ctx.Route()
+	})
+}
+
+// KeyByHeader 按照请求头限流，例如 X-API-Key
+// 请求头的值由客户端控制，只有通过valid校验的才会用来限流，例如检查API Key是否存在
+// 没有这个请求头或者校验不通过的时候按照客户端IP限流，避免每次换一个值就能绕过限制，还会占用越来越多的内存
+func (m *MiddlewareBuilder) KeyByHeader(header string, valid func(value string) bool) *MiddlewareBuilder {
+	if valid == nil {
+		panic("web: 按照请求头限流必须校验请求头的值")
+	}
+	return m.KeyFunc(func(ctx *bilibili_http.Context) string {
+		if value := ctx.Request().Header.Get(header); value != "" && valid(value) {
+			return "header:" + value
+		}
+		return "ip:" + ctx.ClientIP()
+	})
+}
+
+// LimitedFunc 自定义超过限制之后的响应，默认响应429和 {"code":429,"msg":"Too Many Requests"}
+func (m *MiddlewareBuilder) LimitedFunc(fn func(ctx *bilibili_http.Context, res Result)) *MiddlewareBuilder {
+	m.limitedFunc = fn
+	return m
+}
+
+// FailClosed Store出错的时候响应503，默认是记录日志之后放行
+func (m *MiddlewareBuilder) FailClosed() *MiddlewareBuilder {
+	m.failClosed = true
+	return m
+}
+
+// WithoutHeaders 不输出 RateLimit-Limit、RateLimit-Remaining、RateLimit-Reset 响应头
+// 被拒绝的时候依然会输出 Retry-After
+func (m *MiddlewareBuilder) WithoutHeaders() *MiddlewareBuilder {
+	m.headers = false
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() bilibili_http.MiddlewareHandleFunc {
+	ttl := m.algorithm.TTL()
+	// 没有设置Store的时候才创建，内存存储的清理在使用之后才开始，所有的key都过期之后自动停止
+	if m.store == nil {
+		m.store = NewMemoryStore(0, 0)
+	}
+	return func(next bilibili_http.HandleFunc) bilibili_http.HandleFunc {
+		return func(ctx *bilibili_http.Context) {
+			key := m.keyFunc(ctx)
+			if key == "" {
+				next(ctx)
+				return
+			}
+			var res Result
+			now := m.now()
+			// Context 本身就实现了 context.Context，请求取消的时候外部存储的调用也能及时返回
+			err := m.store.Update(ctx, m.prefix+key, now, ttl, func(state *State) {
+				res = m.algorithm.Take(state, now)
+			})
+			if err != nil {
+				ctx.Logger().Error("限流状态更新失败", bilibili_http.F("key", key), bilibili_http.F("error", err))
+				if m.failClosed {
+					ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, bilibili_http.NewHTTPError(http.StatusServiceUnavailable, ""))
+					return
+				}
+				next(ctx)
+				return
+			}
+			if m.headers {
+				ctx.SetHeader("RateLimit-Limit", strconv.Itoa(res.Limit))
+				ctx.SetHeader("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+				ctx.SetHeader("RateLimit-Reset", seconds(res.Reset))
+			}
+			if !res.Allowed {
+				ctx.SetHeader("Retry-After", seconds(res.RetryAfter))
+				m.limitedFunc(ctx, res)
+				// 自定义的响应忘了中断的话，这里兜底
+				ctx.Abort()
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+// NewMiddleware 使用algorithm限流，默认按照客户端IP，状态保存在内存中
+func NewMiddleware(algorithm Algorithm) *MiddlewareBuilder {
+	m := &MiddlewareBuilder{
+		algorithm:   algorithm,
+		prefix:      "ratelimit:",
+		limitedFunc: tooManyRequests,
+		headers:     true,
+		now:         time.Now,
+	}
+	return m.KeyByIP()
+}
+
+func tooManyRequests(ctx *bilibili_http.Context, _ Result) {
+	ctx.AbortWithStatusJSON(http.StatusTooManyRequests, bilibili_http.NewHTTPError(http.StatusTooManyRequests, ""))
+}
+
+// seconds 响应头中的时间单位是秒，向上取整，避免客户端过早重试
+func seconds(d time.Duration) string {
+	if d <= 0 {
+		return "0"
+	}
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
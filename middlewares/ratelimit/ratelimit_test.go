@@ -0,0 +1,244 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/borntodie-new/bilibili-http"
+)
+
+// fakeStore 记录访问过的key，err不为nil的时候返回错误
+type fakeStore struct {
+	states map[string]*State
+	keys   []string
+	err    error
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{states: map[string]*State{}}
+}
+
+func (s *fakeStore) Update(_ context.Context, key string, _ time.Time, _ time.Duration, fn func(state *State)) error {
+	s.keys = append(s.keys, key)
+	if s.err != nil {
+		return s.err
+	}
+	state, ok := s.states[key]
+	if !ok {
+		state = &State{}
+		s.states[key] = state
+	}
+	fn(state)
+	return nil
+}
+
+// TestMiddleware 测试响应头、被拒绝之后的响应以及Store出错的处理
+func TestMiddleware(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 10, 0, time.UTC)
+	testCases := []struct {
+		name    string
+		builder func(m *MiddlewareBuilder)
+		err     error
+		// requests 发送的请求数，只检查最后一个请求的响应
+		requests int
+
+		wantCode   int
+		wantBody   string
+		wantHeader map[string]string
+		wantKeys   []string
+		wantLog    string
+	}{
+		{
+			name:     "allowed",
+			requests: 2,
+			wantCode: http.StatusOK,
+			wantBody: "tom",
+			wantHeader: map[string]string{
+				"RateLimit-Limit":     "2",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "50",
+			},
+			wantKeys: []string{"ratelimit:ip:192.0.2.1", "ratelimit:ip:192.0.2.1"},
+		},
+		{
+			name:     "limited",
+			requests: 3,
+			wantCode: http.StatusTooManyRequests,
+			wantBody: `{"code":429,"msg":"Too Many Requests"}`,
+			wantHeader: map[string]string{
+				"RateLimit-Limit":     "2",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "50",
+				"Retry-After":         "50",
+			},
+			wantKeys: []string{"ratelimit:ip:192.0.2.1", "ratelimit:ip:192.0.2.1", "ratelimit:ip:192.0.2.1"},
+		},
+		{
+			// 不输出 RateLimit-* 的时候依然会输出 Retry-After
+			name: "without headers",
+			builder: func(m *MiddlewareBuilder) {
+				m.WithoutHeaders().Prefix("api:")
+			},
+			requests:   3,
+			wantCode:   http.StatusTooManyRequests,
+			wantBody:   `{"code":429,"msg":"Too Many Requests"}`,
+			wantHeader: map[string]string{"Retry-After": "50"},
+			wantKeys:   []string{"api:ip:192.0.2.1", "api:ip:192.0.2.1", "api:ip:192.0.2.1"},
+		},
+		{
+			// 自定义的响应忘了中断，视图函数也不会执行
+			name: "limited func",
+			builder: func(m *MiddlewareBuilder) {
+				m.LimitedFunc(func(ctx *bilibili_http.Context, res Result) {
+					ctx.TEXT(http.StatusTooManyRequests, "slow down")
+				})
+			},
+			requests: 3,
+			wantCode: http.StatusTooManyRequests,
+			wantBody: "slow down",
+			wantHeader: map[string]string{
+				"RateLimit-Limit":     "2",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "50",
+				"Retry-After":         "50",
+			},
+			wantKeys: []string{"ratelimit:ip:192.0.2.1", "ratelimit:ip:192.0.2.1", "ratelimit:ip:192.0.2.1"},
+		},
+		{
+			// 默认Store出错的时候放行
+			name:     "fail open",
+			err:      errors.New("redis down"),
+			requests: 1,
+			wantCode: http.StatusOK,
+			wantBody: "tom",
+			wantKeys: []string{"ratelimit:ip:192.0.2.1"},
+			wantLog:  `level=ERROR msg=限流状态更新失败 method=GET path=/user/1 route=/user/:id key=ip:192.0.2.1 error="redis down"`,
+		},
+		{
+			name: "fail closed",
+			builder: func(m *MiddlewareBuilder) {
+				m.FailClosed()
+			},
+			err:      errors.New("redis down"),
+			requests: 1,
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"code":503,"msg":"Service Unavailable"}`,
+			wantKeys: []string{"ratelimit:ip:192.0.2.1"},
+			wantLog:  `level=ERROR msg=限流状态更新失败`,
+		},
+		{
+			// 返回空字符串的请求不限流，也不会访问Store
+			name: "empty key",
+			builder: func(m *MiddlewareBuilder) {
+				m.KeyFunc(func(ctx *bilibili_http.Context) string {
+					return ""
+				})
+			},
+			err:      errors.New("redis down"),
+			requests: 3,
+			wantCode: http.StatusOK,
+			wantBody: "tom",
+		},
+		{
+			name: "key by route",
+			builder: func(m *MiddlewareBuilder) {
+				m.KeyByRoute()
+			},
+			requests: 1,
+			wantCode: http.StatusOK,
+			wantBody: "tom",
+			wantHeader: map[string]string{
+				"RateLimit-Limit":     "2",
+				"RateLimit-Remaining": "1",
+				"RateLimit-Reset":     "50",
+			},
+			wantKeys: []string{"ratelimit:route:GET /user/:id"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newFakeStore()
+			store.err = tc.err
+			m := NewMiddleware(FixedWindow(2, time.Minute)).Store(store)
+			m.now = func() time.Time {
+				return now
+			}
+			if tc.builder != nil {
+				tc.builder(m)
+			}
+			buf := &bytes.Buffer{}
+			h := bilibili_http.NewHTTP(bilibili_http.WithLogger(bilibili_http.NewStdLogger(buf, bilibili_http.LevelError)))
+			h.Use(m.Build())
+			h.GET("/user/:id", func(ctx *bilibili_http.Context) {
+				ctx.TEXT(http.StatusOK, "tom")
+			})
+			var recorder *httptest.ResponseRecorder
+			for i := 0; i < tc.requests; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+				req.RemoteAddr = "192.0.2.1:1234"
+				recorder = httptest.NewRecorder()
+				h.ServeHTTP(recorder, req)
+			}
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			for _, key := range []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"} {
+				assert.Equal(t, tc.wantHeader[key], recorder.Header().Get(key), key)
+			}
+			assert.Equal(t, tc.wantKeys, store.keys)
+			if tc.wantLog == "" {
+				assert.Empty(t, buf.String())
+			} else {
+				assert.Contains(t, buf.String(), tc.wantLog)
+			}
+		})
+	}
+}
+
+// TestMiddlewareKeyByHeader 只有校验通过的请求头才会用来限流，否则按照客户端IP
+func TestMiddlewareKeyByHeader(t *testing.T) {
+	store := newFakeStore()
+	m := NewMiddleware(FixedWindow(1, time.Minute)).Store(store).KeyByHeader("X-API-Key", func(value string) bool {
+		return strings.HasPrefix(value, "key-")
+	})
+	h := bilibili_http.NewHTTP(bilibili_http.WithLogger(bilibili_http.NopLogger()))
+	h.Use(m.Build())
+	h.GET("/user", func(ctx *bilibili_http.Context) {
+		ctx.TEXT(http.StatusOK, "tom")
+	})
+	codes := make([]int, 0, 4)
+	for _, key := range []string{"key-1", "", "random-1", "random-2"} {
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, req)
+		codes = append(codes, recorder.Code)
+	}
+	// 伪造的请求头和没有请求头一样，共用客户端IP的配额
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}, codes)
+	assert.Equal(t, []string{"ratelimit:header:key-1", "ratelimit:ip:192.0.2.1", "ratelimit:ip:192.0.2.1", "ratelimit:ip:192.0.2.1"}, store.keys)
+	assert.Len(t, store.states, 2)
+
+	assert.PanicsWithValue(t, "web: 按照请求头限流必须校验请求头的值", func() {
+		NewMiddleware(FixedWindow(1, time.Minute)).KeyByHeader("X-API-Key", nil)
+	})
+}
+
+// TestSeconds 响应头中的秒数向上取整
+func TestSeconds(t *testing.T) {
+	assert.Equal(t, "0", seconds(0))
+	assert.Equal(t, "0", seconds(-time.Second))
+	assert.Equal(t, "1", seconds(time.Millisecond))
+	assert.Equal(t, "2", seconds(1500*time.Millisecond))
+	assert.Equal(t, "60", seconds(time.Minute))
+}
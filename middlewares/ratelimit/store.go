@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store 保存每个key的限流状态
+// 多个实例共享限流状态的时候，基于Redis之类的外部存储实现这个接口
+type Store interface {
+	// Update 原子地读取并修改key对应的状态，key不存在或者已经过期的时候state是零值
+	// now 是限流器的当前时间，和传给 Algorithm.Take 的是同一个，过期时间以它为准
+	// fn返回之后把state保存下来，ttl时间内没有再访问的话可以清理掉
+	// 同一个key的并发调用必须是串行的，外部存储可以使用乐观锁或者脚本保证
+	Update(ctx context.Context, key string, now time.Time, ttl time.Duration, fn func(state *State)) error
+}
+
+// MemoryStore 单机的内存存储，按照key的哈希值分片加锁，减少锁竞争
+// 第一次写入之后后台开始定期清理过期的key，所有的key都清理掉之后自动停止，下次写入的时候再开始
+// 不再使用的时候也可以调用 Close 立即停止清理
+type MemoryStore struct {
+	shards   []*memoryShard
+	interval time.Duration
+	// running 后台的清理是否正在运行，只在cleanupMutex中修改
+	running      int32
+	cleanupMutex sync.Mutex
+	stop         chan struct{}
+	once         sync.Once
+}
+
+type memoryShard struct {
+	mutex   sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+type memoryEntry struct {
+	state State
+	// expireAt 按照限流器的时钟计算的过期时间，Update的时候判断状态是否需要重置
+	expireAt time.Time
+	// evictAt 按照真实时间计算的过期时间，后台清理的时候使用
+	evictAt time.Time
+}
+
+// NewMemoryStore shards 分片的数量，cleanupInterval 清理过期key的间隔
+func NewMemoryStore(shards int, cleanupInterval time.Duration) *MemoryStore {
+	if shards <= 0 {
+		shards = 32
+	}
+	if cleanupInterval <= 0 {
+		cleanupInterval = time.Minute
+	}
+	s := &MemoryStore{
+		shards:   make([]*memoryShard, shards),
+		interval: cleanupInterval,
+		stop:     make(chan struct{}),
+	}
+	for i := range s.shards {
+		s.shards[i] = &memoryShard{entries: map[string]*memoryEntry{}}
+	}
+	return s
+}
+
+func (s *MemoryStore) Update(_ context.Context, key string, now time.Time, ttl time.Duration, fn func(state *State)) error {
+	shard := s.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	entry, ok := shard.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+		shard.entries[key] = entry
+	} else if now.After(entry.expireAt) {
+		// 还没来得及清理的过期key，当成新的处理
+		entry.state = State{}
+	}
+	fn(&entry.state)
+	entry.expireAt = now.Add(ttl)
+	entry.evictAt = time.Now().Add(ttl)
+	s.startCleanup()
+	return nil
+}
+
+// Len 当前保存的key的数量
+func (s *MemoryStore) Len() int {
+	res := 0
+	for _, shard := range s.shards {
+		shard.mutex.Lock()
+		res += len(shard.entries)
+		shard.mutex.Unlock()
+	}
+	return res
+}
+
+// Close 停止后台的清理，之后写入也不会再启动
+func (s *MemoryStore) Close() error {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+	return nil
+}
+
+func (s *MemoryStore) shard(key string) *memoryShard {
+	// FNV-1a，直接展开计算可以避免每次请求都分配内存
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return s.shards[h%uint32(len(s.shards))]
+}
+
+// startCleanup 后台的清理没有运行的时候启动，大部分时候只有一次原子读
+func (s *MemoryStore) startCleanup() {
+	if atomic.LoadInt32(&s.running) == 1 {
+		return
+	}
+	s.cleanupMutex.Lock()
+	defer s.cleanupMutex.Unlock()
+	if atomic.LoadInt32(&s.running) == 1 {
+		return
+	}
+	select {
+	case <-s.stop:
+		// 已经关闭了
+		return
+	default:
+	}
+	atomic.StoreInt32(&s.running, 1)
+	go s.cleanup()
+}
+
+func (s *MemoryStore) cleanup() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.evict(now)
+			if s.idle() {
+				return
+			}
+		}
+	}
+}
+
+// idle 所有的key都已经清理掉了，停止后台的清理，避免不再使用的存储一直占用goroutine
+func (s *MemoryStore) idle() bool {
+	s.cleanupMutex.Lock()
+	defer s.cleanupMutex.Unlock()
+	if s.Len() > 0 {
+		return false
+	}
+	atomic.StoreInt32(&s.running, 0)
+	return true
+}
+
+// evict 一个分片一个分片地清理，不会长时间占用所有的锁
+func (s *MemoryStore) evict(now time.Time) {
+	for _, shard := range s.shards {
+		shard.mutex.Lock()
+		for key, entry := range shard.entries {
+			if now.After(entry.evictAt) {
+				delete(shard.entries, key)
+			}
+		}
+		shard.mutex.Unlock()
+	}
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMemoryStore 测试过期之后状态重置以及并发的更新
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore(4, time.Hour)
+	defer s.Close()
+	update := func(key string, ttl time.Duration) int64 {
+		var count int64
+		require.NoError(t, s.Update(context.Background(), key, time.Now(), ttl, func(state *State) {
+			state.Count++
+			count = state.Count
+		}))
+		return count
+	}
+	assert.Equal(t, int64(1), update("a", time.Hour))
+	assert.Equal(t, int64(2), update("a", time.Hour))
+	// 过期了但是还没有清理的key当成新的
+	assert.Equal(t, int64(1), update("b", -time.Second))
+	assert.Equal(t, int64(1), update("b", time.Hour))
+	assert.Equal(t, 2, s.Len())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			update("c", time.Hour)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(101), update("c", time.Hour))
+
+	s.evict(time.Now().Add(2 * time.Hour))
+	assert.Equal(t, 0, s.Len())
+}
+
+// TestMemoryStoreClock 测试过期时间使用限流器传进来的时钟，后台清理使用真实时间
+func TestMemoryStoreClock(t *testing.T) {
+	s := NewMemoryStore(4, time.Hour)
+	defer s.Close()
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	update := func(now time.Time) int64 {
+		var count int64
+		require.NoError(t, s.Update(context.Background(), "a", now, time.Minute, func(state *State) {
+			state.Count++
+			count = state.Count
+		}))
+		return count
+	}
+	assert.Equal(t, int64(1), update(now))
+	// 真实时间早就超过了过期时间，但是限流器的时钟还没有
+	assert.Equal(t, int64(2), update(now.Add(30*time.Second)))
+	// 真实时间没怎么变，限流器的时钟已经过期了
+	assert.Equal(t, int64(1), update(now.Add(2*time.Minute)))
+
+	// 后台清理按照真实时间，不会提前清理掉还在使用的key
+	s.evict(time.Now())
+	assert.Equal(t, 1, s.Len())
+	s.evict(time.Now().Add(2 * time.Minute))
+	assert.Equal(t, 0, s.Len())
+}
+
+// TestMemoryStoreCleanup 清理在第一次写入之后才开始，所有的key都清理掉之后自动停止
+func TestMemoryStoreCleanup(t *testing.T) {
+	s := NewMemoryStore(0, 10*time.Millisecond)
+	// 创建之后没有使用，不会启动后台的goroutine
+	assert.Equal(t, int32(0), atomic.LoadInt32(&s.running))
+
+	require.NoError(t, s.Update(context.Background(), "a", time.Now(), 20*time.Millisecond, func(state *State) {}))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&s.running))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&s.running) == 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 0, s.Len())
+
+	// 再次写入的时候重新启动
+	require.NoError(t, s.Update(context.Background(), "b", time.Now(), time.Hour, func(state *State) {}))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&s.running))
+	require.NoError(t, s.Close())
+	require.NoError(t, s.Close())
+
+	// 关闭之后依然可以写入，只是不再清理
+	s = NewMemoryStore(0, time.Millisecond)
+	require.NoError(t, s.Close())
+	require.NoError(t, s.Update(context.Background(), "a", time.Now(), time.Hour, func(state *State) {}))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&s.running))
+}
@@ -0,0 +1,99 @@
+package recovery
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/borntodie-new/bilibili-http"
+)
+
+// MiddlewareBuilder 可配置的panic恢复中间件
+// 用来替换框架内置的recovery：
+// bilibili_http.NewHTTP(bilibili_http.WithDefaultMiddleware(recovery.NewMiddleware().Build()))
+type MiddlewareBuilder struct {
+	// logFunc 记录panic的报告
+	logFunc func(ctx *bilibili_http.Context, report *Report)
+	// hooks panic之后依次调用，例如上报到错误追踪系统
+	hooks []func(ctx *bilibili_http.Context, report *Report)
+	// renderFunc 给客户端的响应
+	renderFunc func(ctx *bilibili_http.Context, report *Report)
+}
+
+// LogFunc 自定义记录panic报告的方式，默认是 bilibili_http.LogPanic
+func (m *MiddlewareBuilder) LogFunc(fn func(ctx *bilibili_http.Context, report *Report)) *MiddlewareBuilder {
+	m.logFunc = fn
+	return m
+}
+
+// OnPanic 注册panic之后调用的钩子，可以注册多个，按照注册的顺序调用
+// 钩子自己panic的话会被忽略，不会影响给客户端的响应
+func (m *MiddlewareBuilder) OnPanic(hook func(ctx *bilibili_http.Context, report *Report)) *MiddlewareBuilder {
+	m.hooks = append(m.hooks, hook)
+	return m
+}
+
+// RenderFunc 自定义给客户端的响应，默认是 bilibili_http.RenderPanic
+func (m *MiddlewareBuilder) RenderFunc(fn func(ctx *bilibili_http.Context, report *Report)) *MiddlewareBuilder {
+	m.renderFunc = fn
+	return m
+}
+
+// ProblemDetails 按照 RFC 9457 响应 application/problem+json 格式的错误
+func (m *MiddlewareBuilder) ProblemDetails() *MiddlewareBuilder {
+	m.renderFunc = renderProblem
+	return m
+}
+
+// Build 和框架内置的recovery是同一个实现，只是多了钩子和自定义的日志、响应
+func (m *MiddlewareBuilder) Build() bilibili_http.MiddlewareHandleFunc {
+	return bilibili_http.Recovery(func(ctx *bilibili_http.Context, report *Report) {
+		m.logFunc(ctx, report)
+		for _, hook := range m.hooks {
+			callHook(hook, ctx, report)
+		}
+		// 连接已经断开了，写什么客户端都收不到
+		if report.BrokenPipe {
+			return
+		}
+		m.renderFunc(ctx, report)
+	})
+}
+
+func NewMiddleware() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		logFunc:    bilibili_http.LogPanic,
+		renderFunc: bilibili_http.RenderPanic,
+	}
+}
+
+func callHook(hook func(ctx *bilibili_http.Context, report *Report), ctx *bilibili_http.Context, report *Report) {
+	defer func() {
+		_ = recover()
+	}()
+	hook(ctx, report)
+}
+
+// Report 一次panic的报告，和框架内置的recovery使用同一个类型
+type Report = bilibili_http.PanicReport
+
+// problem RFC 9457 定义的错误格式
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func renderProblem(ctx *bilibili_http.Context, report *Report) {
+	data, _ := json.Marshal(problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(http.StatusInternalServerError),
+		Status:    http.StatusInternalServerError,
+		Instance:  report.Path,
+		RequestID: report.RequestID,
+	})
+	ctx.SetHeader("Content-Type", "application/problem+json")
+	ctx.SetStatusCode(http.StatusInternalServerError)
+	ctx.SetData(data)
+}
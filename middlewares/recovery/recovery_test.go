@@ -0,0 +1,206 @@
+package recovery
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/borntodie-new/bilibili-http"
+)
+
+// TestMiddleware 测试panic之后的响应、日志和钩子
+func TestMiddleware(t *testing.T) {
+	brokenPipe := &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}
+	testCases := []struct {
+		name    string
+		builder func() *MiddlewareBuilder
+		// panicValue 视图函数panic的值
+		panicValue any
+		// requestID 设置请求ID的方式
+		requestID func(ctx *bilibili_http.Context)
+
+		wantCode        int
+		wantContentType string
+		wantBody        string
+		wantLog         string
+		wantReport      Report
+	}{
+		{
+			name:       "text",
+			builder:    NewMiddleware,
+			panicValue: "db down",
+			wantCode:   http.StatusInternalServerError,
+			// 和框架内置的recovery保持一致
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "Server Internal Error, Please Try Again Later!",
+			wantLog:         `level=ERROR msg=请求处理过程中发生panic method=GET path=/user/1 route=/user/:id error="db down" stack=`,
+			wantReport:      Report{Err: "db down", Method: http.MethodGet, Path: "/user/1", Route: "/user/:id"},
+		},
+		{
+			name: "problem details",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().ProblemDetails()
+			},
+			panicValue: errors.New("db down"),
+			requestID: func(ctx *bilibili_http.Context) {
+				ctx.Set(bilibili_http.RequestIDKey, "abc-123")
+			},
+			wantCode:        http.StatusInternalServerError,
+			wantContentType: "application/problem+json",
+			wantBody:        `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/user/1","request_id":"abc-123"}`,
+			wantLog:         `level=ERROR msg=请求处理过程中发生panic method=GET path=/user/1 route=/user/:id request_id=abc-123 error="db down" stack=`,
+			wantReport: Report{Err: errors.New("db down"), Method: http.MethodGet, Path: "/user/1",
+				Route: "/user/:id", RequestID: "abc-123"},
+		},
+		{
+			// 其他方式设置在响应头中的请求ID也会记录下来
+			name:       "request id header",
+			builder:    NewMiddleware,
+			panicValue: "db down",
+			requestID: func(ctx *bilibili_http.Context) {
+				ctx.SetHeader("X-Request-ID", "from-header")
+			},
+			wantCode:        http.StatusInternalServerError,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "Server Internal Error, Please Try Again Later!",
+			wantLog:         `route=/user/:id request_id=from-header error="db down" stack=`,
+			wantReport: Report{Err: "db down", Method: http.MethodGet, Path: "/user/1",
+				Route: "/user/:id", RequestID: "from-header"},
+		},
+		{
+			// 客户端已经断开了，不响应，只记录一条警告，也不需要调用栈
+			name:       "broken pipe",
+			builder:    NewMiddleware,
+			panicValue: brokenPipe,
+			wantCode:   http.StatusOK,
+			wantLog:    `level=WARN msg=客户端断开连接 method=GET path=/user/1 route=/user/:id error="write tcp: write: broken pipe"`,
+			wantReport: Report{Err: brokenPipe, Method: http.MethodGet, Path: "/user/1",
+				Route: "/user/:id", BrokenPipe: true},
+		},
+		{
+			name: "custom render",
+			builder: func() *MiddlewareBuilder {
+				return NewMiddleware().RenderFunc(func(ctx *bilibili_http.Context, report *Report) {
+					ctx.JSON(http.StatusServiceUnavailable, map[string]string{"error": report.Error()})
+				})
+			},
+			panicValue:      "db down",
+			wantCode:        http.StatusServiceUnavailable,
+			wantContentType: "application/json; charset=utf-8",
+			wantBody:        `{"error":"db down"}`,
+			wantLog:         `level=ERROR msg=请求处理过程中发生panic`,
+			wantReport:      Report{Err: "db down", Method: http.MethodGet, Path: "/user/1", Route: "/user/:id"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			var reports []*Report
+			var calls []string
+			builder := tc.builder().
+				OnPanic(func(ctx *bilibili_http.Context, report *Report) {
+					calls = append(calls, "first")
+					reports = append(reports, report)
+				}).
+				OnPanic(func(ctx *bilibili_http.Context, report *Report) {
+					// 钩子panic了不影响后面的钩子和给客户端的响应
+					panic("hook down")
+				}).
+				OnPanic(func(ctx *bilibili_http.Context, report *Report) {
+					calls = append(calls, "third")
+				})
+			h := bilibili_http.NewHTTP(bilibili_http.WithLogger(bilibili_http.NewStdLogger(buf, bilibili_http.LevelDebug)),
+				bilibili_http.WithDefaultMiddleware(builder.Build()))
+			afterPanic := false
+			h.GET("/user/:id", func(ctx *bilibili_http.Context) {
+				if tc.requestID != nil {
+					tc.requestID(ctx)
+				}
+				panic(tc.panicValue)
+			}, func(next bilibili_http.HandleFunc) bilibili_http.HandleFunc {
+				return func(ctx *bilibili_http.Context) {
+					next(ctx)
+					afterPanic = true
+				}
+			})
+			recorder := httptest.NewRecorder()
+			start := time.Now()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user/1", nil))
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantContentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Contains(t, buf.String(), tc.wantLog)
+			assert.False(t, afterPanic)
+			assert.Equal(t, []string{"first", "third"}, calls)
+
+			require.Len(t, reports, 1)
+			report := reports[0]
+			assert.WithinDuration(t, start, report.Time, time.Second)
+			if tc.wantReport.BrokenPipe {
+				assert.Empty(t, report.Stack)
+			} else {
+				assert.Contains(t, string(report.Stack), "recovery_test.go")
+			}
+			report.Time, report.Stack = time.Time{}, nil
+			assert.Equal(t, tc.wantReport, *report)
+		})
+	}
+}
+
+// TestMiddlewareAbortHandler 测试 http.ErrAbortHandler 继续往上抛，交给http.Server断开连接
+func TestMiddlewareAbortHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	hooked := false
+	h := bilibili_http.NewHTTP(bilibili_http.WithLogger(bilibili_http.NewStdLogger(buf, bilibili_http.LevelDebug)),
+		bilibili_http.WithDefaultMiddleware(NewMiddleware().OnPanic(func(ctx *bilibili_http.Context, report *Report) {
+			hooked = true
+		}).Build()))
+	h.GET("/download", func(ctx *bilibili_http.Context) {
+		panic(http.ErrAbortHandler)
+	})
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/download", nil))
+	})
+	assert.False(t, hooked)
+	assert.NotContains(t, buf.String(), "panic")
+}
+
+// TestMiddlewareLogFunc 测试自定义记录报告的方式
+func TestMiddlewareLogFunc(t *testing.T) {
+	var logs []string
+	h := bilibili_http.NewHTTP(bilibili_http.WithLogger(bilibili_http.NopLogger()),
+		bilibili_http.WithDefaultMiddleware(NewMiddleware().LogFunc(func(ctx *bilibili_http.Context, report *Report) {
+			report.Time = time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+			report.Stack = []byte("stack")
+			logs = append(logs, report.String())
+		}).Build()))
+	h.Use(func(next bilibili_http.HandleFunc) bilibili_http.HandleFunc {
+		return func(ctx *bilibili_http.Context) {
+			ctx.Set(bilibili_http.RequestIDKey, "abc-123")
+			next(ctx)
+		}
+	})
+	h.GET("/user/:id", func(ctx *bilibili_http.Context) {
+		panic(fmt.Errorf("user %d: %w", 1, errors.New("db down")))
+	})
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user/1", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, []string{strings.Join([]string{
+		"[Recovery] 2023-05-01T12:00:00Z panic: user 1: db down",
+		"GET /user/1 (/user/:id) request_id=abc-123",
+		"stack",
+	}, "\n")}, logs)
+}
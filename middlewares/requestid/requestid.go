@@ -0,0 +1,164 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/borntodie-new/bilibili-http"
+)
+
+// MiddlewareBuilder 请求ID中间件
+// 请求头中带了合法的请求ID就直接使用，否则生成一个新的，然后：
+// 1. 设置到响应头中，方便客户端反馈问题的时候提供
+// 2. 保存到上下文的键值对中，通过 ctx.RequestID() 获取，访问日志、recovery和 ctx.Logger() 都会带上
+// 3. 保存到请求的context中，通过 FromContext 获取，传给数据库、RPC之类的下游库使用
+// 推荐注册成全局中间件，404的请求也会有请求ID：
+// h.Use(requestid.NewMiddleware().Build())
+type MiddlewareBuilder struct {
+	// header 读取和写入请求ID的头部，默认是 X-Request-ID
+	header string
+	// generator 生成请求ID，默认是UUIDv4
+	generator func() string
+	// validator 校验请求头中的请求ID，不合法的直接丢弃，重新生成一个
+	validator func(id string) bool
+	// ignoreIncoming 不使用请求头中的请求ID，总是重新生成
+	ignoreIncoming bool
+}
+
+// Header 修改请求ID使用的头部，例如 X-Trace-ID
+func (m *MiddlewareBuilder) Header(header string) *MiddlewareBuilder {
+	m.header = header
+	return m
+}
+
+// Generator 自定义生成请求ID的方式，可以使用 UUIDv4、ULID，或者自己的雪花算法
+func (m *MiddlewareBuilder) Generator(fn func() string) *MiddlewareBuilder {
+	m.generator = fn
+	return m
+}
+
+// Validator 自定义校验请求头中的请求ID，默认只接受不超过128个字符的字母、数字和 -_.:
+// 请求ID会原样出现在日志和响应头中，不校验的话客户端可以伪造日志内容
+func (m *MiddlewareBuilder) Validator(fn func(id string) bool) *MiddlewareBuilder {
+	m.validator = fn
+	return m
+}
+
+// IgnoreIncoming 总是生成新的请求ID，适合直接暴露在公网、没有网关的服务
+func (m *MiddlewareBuilder) IgnoreIncoming() *MiddlewareBuilder {
+	m.ignoreIncoming = true
+	return m
+}
+
+func (m *MiddlewareBuilder) Build() bilibili_http.MiddlewareHandleFunc {
+	return func(next bilibili_http.HandleFunc) bilibili_http.HandleFunc {
+		return func(ctx *bilibili_http.Context) {
+			id := ""
+			if !m.ignoreIncoming {
+				id = ctx.Request().Header.Get(m.header)
+				if id != "" && !m.validator(id) {
+					id = ""
+				}
+			}
+			if id == "" {
+				id = m.generator()
+			}
+			ctx.SetHeader(m.header, id)
+			ctx.Set(bilibili_http.RequestIDKey, id)
+			ctx.WithValue(contextKey{}, id)
+			next(ctx)
+		}
+	}
+}
+
+func NewMiddleware() *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		header:    "X-Request-ID",
+		generator: UUIDv4,
+		validator: Valid,
+	}
+}
+
+// contextKey 请求ID在请求的context中的key，不导出避免和其他包冲突
+type contextKey struct{}
+
+// FromContext 从请求的context中获取请求ID，没有的话为空
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Valid 默认的校验规则，只接受不超过128个字符的字母、数字和 -_.:
+func Valid(id string) bool {
+	if len(id) == 0 || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		ch := id[i]
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-', ch == '_', ch == '.', ch == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// UUIDv4 生成随机的UUID，例如 0b7e3c4a-9f1d-4c7e-8a52-3f6d2e1b9c80
+func UUIDv4() string {
+	var b [16]byte
+	random(b[:])
+	// 版本号4和RFC 4122的变体
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf[:])
+}
+
+// crockford ULID使用的base32字符表，去掉了容易混淆的 I L O U
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID 生成按照时间排序的ID，例如 01H0Z8Y7RJ3K5Q6W8E9T2V4B6N
+// 前10个字符是毫秒时间戳，后16个字符是随机数，字典序和生成的时间顺序一致，适合作为日志的检索条件
+func ULID() string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+	random(b[6:])
+	// 128位按照每5位一个字符编码，最前面补两个0位凑够130位
+	var buf [26]byte
+	var acc uint64
+	bits := 2
+	j := 0
+	for _, v := range b {
+		acc = acc<<8 | uint64(v)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			buf[j] = crockford[(acc>>uint(bits))&0x1f]
+			j++
+		}
+	}
+	return string(buf[:])
+}
+
+func random(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic("web: 生成随机数失败 " + err.Error())
+	}
+}
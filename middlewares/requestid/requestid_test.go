@@ -0,0 +1,196 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/borntodie-new/bilibili-http"
+)
+
+var (
+	uuidRegexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	ulidRegexp = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+)
+
+// TestMiddleware 测试使用、校验以及生成请求ID
+func TestMiddleware(t *testing.T) {
+	testCases := []struct {
+		name    string
+		builder *MiddlewareBuilder
+		header  map[string]string
+
+		// wantID 为空的时候表示应该是新生成的
+		wantID       string
+		wantHeader   string
+		wantGenerate bool
+	}{
+		{
+			name:       "incoming",
+			builder:    NewMiddleware(),
+			header:     map[string]string{"X-Request-ID": "abc-123"},
+			wantID:     "abc-123",
+			wantHeader: "X-Request-ID",
+		},
+		{
+			name:         "missing",
+			builder:      NewMiddleware(),
+			wantHeader:   "X-Request-ID",
+			wantGenerate: true,
+		},
+		{
+			// 不合法的请求ID直接丢弃，避免伪造日志内容
+			name:         "invalid",
+			builder:      NewMiddleware(),
+			header:       map[string]string{"X-Request-ID": "abc 123\nlevel=ERROR"},
+			wantHeader:   "X-Request-ID",
+			wantGenerate: true,
+		},
+		{
+			name:         "too long",
+			builder:      NewMiddleware(),
+			header:       map[string]string{"X-Request-ID": strings.Repeat("a", 129)},
+			wantHeader:   "X-Request-ID",
+			wantGenerate: true,
+		},
+		{
+			name:         "ignore incoming",
+			builder:      NewMiddleware().IgnoreIncoming(),
+			header:       map[string]string{"X-Request-ID": "abc-123"},
+			wantHeader:   "X-Request-ID",
+			wantGenerate: true,
+		},
+		{
+			name:       "custom header",
+			builder:    NewMiddleware().Header("X-Trace-ID"),
+			header:     map[string]string{"X-Request-ID": "abc-123", "X-Trace-ID": "trace-1"},
+			wantID:     "trace-1",
+			wantHeader: "X-Trace-ID",
+		},
+		{
+			name: "custom validator",
+			builder: NewMiddleware().Validator(func(id string) bool {
+				return strings.HasPrefix(id, "req-")
+			}),
+			header:       map[string]string{"X-Request-ID": "abc-123"},
+			wantHeader:   "X-Request-ID",
+			wantGenerate: true,
+		},
+		{
+			name: "custom generator",
+			builder: NewMiddleware().Generator(func() string {
+				return "generated"
+			}),
+			wantID:     "generated",
+			wantHeader: "X-Request-ID",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := bilibili_http.NewHTTP(bilibili_http.WithLogger(bilibili_http.NopLogger()))
+			h.Use(tc.builder.Build())
+			var id, fromContext string
+			h.GET("/user", func(ctx *bilibili_http.Context) {
+				id = ctx.RequestID()
+				fromContext = FromContext(ctx)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/user", nil)
+			for key, value := range tc.header {
+				req.Header.Set(key, value)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			if tc.wantGenerate {
+				assert.Regexp(t, uuidRegexp, id)
+			} else {
+				assert.Equal(t, tc.wantID, id)
+			}
+			// 响应头、上下文的键值对以及请求的context中是同一个请求ID
+			assert.Equal(t, id, recorder.Header().Get(tc.wantHeader))
+			assert.Equal(t, id, fromContext)
+		})
+	}
+
+	// 没有匹配到路由的请求也有请求ID
+	h := bilibili_http.NewHTTP(bilibili_http.WithLogger(bilibili_http.NopLogger()))
+	h.Use(NewMiddleware().Build())
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Regexp(t, uuidRegexp, recorder.Header().Get("X-Request-ID"))
+}
+
+// TestFromContext 没有经过中间件的context中没有请求ID
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, "", FromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context()))
+}
+
+// TestValid 测试默认的校验规则
+func TestValid(t *testing.T) {
+	testCases := []struct {
+		id   string
+		want bool
+	}{
+		{id: "abc-123", want: true},
+		{id: "0b7e3c4a-9f1d-4c7e-8a52-3f6d2e1b9c80", want: true},
+		{id: "trace_1.span:2", want: true},
+		{id: strings.Repeat("a", 128), want: true},
+		{id: "", want: false},
+		{id: strings.Repeat("a", 129), want: false},
+		{id: "abc 123", want: false},
+		{id: "abc\n123", want: false},
+		{id: `abc"123`, want: false},
+		{id: "请求", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.id, func(t *testing.T) {
+			assert.Equal(t, tc.want, Valid(tc.id))
+		})
+	}
+}
+
+// TestGenerator 测试UUIDv4和ULID的格式，生成的ID不能重复
+func TestGenerator(t *testing.T) {
+	testCases := []struct {
+		name      string
+		generator func() string
+		regexp    *regexp.Regexp
+	}{
+		{name: "uuid", generator: UUIDv4, regexp: uuidRegexp},
+		{name: "ulid", generator: ULID, regexp: ulidRegexp},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			seen := make(map[string]struct{}, 1000)
+			for i := 0; i < 1000; i++ {
+				id := tc.generator()
+				assert.Regexp(t, tc.regexp, id)
+				assert.True(t, Valid(id))
+				seen[id] = struct{}{}
+			}
+			assert.Len(t, seen, 1000)
+		})
+	}
+}
+
+// TestULIDOrder ULID的前10个字符是时间戳，不同毫秒生成的ID按照字典序排列
+func TestULIDOrder(t *testing.T) {
+	ids := make([]string, 0, 5)
+	for i := 0; i < 5; i++ {
+		ids = append(ids, ULID())
+		time.Sleep(2 * time.Millisecond)
+	}
+	assert.True(t, sort.StringsAreSorted(ids))
+	// 时间戳部分解码之后和当前时间一致
+	var ms int64
+	for _, ch := range ids[len(ids)-1][:10] {
+		ms = ms<<5 | int64(strings.IndexRune(crockford, ch))
+	}
+	assert.WithinDuration(t, time.Now(), time.UnixMilli(ms), time.Second)
+}
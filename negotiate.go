@@ -0,0 +1,89 @@
+package bilibili_http
+
+import (
+	"strconv"
+	"strings"
+)
+
+// acceptRange Accept请求头中的一项
+// Accept: text/html, application/json;q=0.9, */*;q=0.1
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// parseAccept 解析Accept请求头
+func parseAccept(accept string) []acceptRange {
+	ranges := make([]acceptRange, 0, 4)
+	for _, item := range strings.Split(accept, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(parts[0]))
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			// 不规范的写法：Accept: *
+			if mediaType != "*" {
+				continue
+			}
+			typ, subtype = "*", "*"
+		}
+		r := acceptRange{typ: typ, subtype: subtype, q: 1}
+		for _, param := range parts[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			r.q = q
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// specificity 匹配程度：完全匹配 > type/* > */*，不匹配返回-1
+func (r acceptRange) specificity(typ, subtype string) int {
+	switch {
+	case r.typ == typ && r.subtype == subtype:
+		return 2
+	case r.typ == typ && r.subtype == "*":
+		return 1
+	case r.typ == "*" && r.subtype == "*":
+		return 0
+	}
+	return -1
+}
+
+// negotiate 根据Accept从offers中选出最合适的一个，返回它的下标
+// 每个offer的权重取最精确匹配的那一项的q值，权重相同的情况下按照offers的顺序
+// 没有Accept的时候直接选第一个，一个都不满足的时候返回-1
+func negotiate(accept string, offers []string) int {
+	if len(offers) == 0 {
+		return -1
+	}
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return 0
+	}
+	best, bestQ := -1, 0.0
+	for i, offer := range offers {
+		typ, subtype, _ := strings.Cut(parseContentType(offer), "/")
+		q, spec := 0.0, -1
+		for _, r := range ranges {
+			if s := r.specificity(typ, subtype); s > spec {
+				q, spec = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,522 @@
+package bilibili_http
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// openapi.go 根据注册的路由生成 OpenAPI 3.1 文档
+// 1. 所有路由都会出现在文档中，路由中的 :id 和 *filepath 转换成 {id} 和 {filepath}
+// 2. Typed 视图函数额外带上参数和返回值的结构：
+//    path tag 的字段是路径参数，query tag 的字段是查询参数，其余按照json tag组成请求体
+//    validate tag 中的 required、min、max、oneof 会转换成对应的约束
+// 3. 普通的视图函数拿不到类型信息，只有路径参数
+
+// OpenAPIInfo 文档的基本信息
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIDocument OpenAPI 3.1 文档，只包含能够从路由中推导出来的部分
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+// OpenAPIComponents 可以复用的结构，文档中通过 $ref 引用
+type OpenAPIComponents struct {
+	Schemas map[string]*OpenAPISchema `json:"schemas,omitempty"`
+}
+
+// OpenAPIOperation 一个路由的一个方法
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter 路径参数或者查询参数
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIRequestBody 请求体
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse 一种状态码的响应
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType 一种Content-Type对应的数据结构
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema JSON Schema的子集
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Enum                 []any                     `json:"enum,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+	MinLength            *int                      `json:"minLength,omitempty"`
+	MaxLength            *int                      `json:"maxLength,omitempty"`
+	MinItems             *int                      `json:"minItems,omitempty"`
+	MaxItems             *int                      `json:"maxItems,omitempty"`
+}
+
+// OpenAPI 根据当前注册的所有路由生成文档
+func (h *HTTPServer) OpenAPI(info OpenAPIInfo) *OpenAPIDocument {
+	return h.openAPI(info, nil)
+}
+
+func (h *HTTPServer) openAPI(info OpenAPIInfo, skip map[string]bool) *OpenAPIDocument {
+	g := &openAPIGenerator{
+		schemas: map[string]*OpenAPISchema{},
+		names:   map[reflect.Type]string{},
+	}
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   map[string]map[string]*OpenAPIOperation{},
+	}
+	for _, route := range h.Routes() {
+		if skip[route.Pattern] {
+			continue
+		}
+		path := openAPIPath(route.Pattern)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = map[string]*OpenAPIOperation{}
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = g.operation(route)
+	}
+	doc.Components.Schemas = g.schemas
+	return doc
+}
+
+// WriteTo 以带缩进的JSON格式输出文档，方便放进代码仓库里做评审
+func (d *OpenAPIDocument) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// SaveFile 把文档导出到文件中
+func (d *OpenAPIDocument) SaveFile(name string) error {
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		return err
+	}
+	return os.WriteFile(name, buf.Bytes(), 0o644)
+}
+
+//go:embed openapi.html
+var openAPIPage string
+
+var openAPIPageTemplate = template.Must(template.New("openapi").Parse(openAPIPage))
+
+// ServeOpenAPI 在specPath上提供JSON格式的文档，在uiPath上提供查看文档的页面
+// 页面不依赖任何外部资源，内网和离线环境都可以使用
+// 文档在第一次请求的时候生成，所以在调用ServeOpenAPI之后注册的路由也会出现在文档中
+func (h *HTTPServer) ServeOpenAPI(specPath string, uiPath string, info OpenAPIInfo) {
+	var (
+		once sync.Once
+		spec []byte
+	)
+	h.GET(specPath, func(ctx *Context) {
+		once.Do(func() {
+			var buf bytes.Buffer
+			_, _ = h.openAPI(info, map[string]bool{specPath: true, uiPath: true}).WriteTo(&buf)
+			spec = buf.Bytes()
+		})
+		ctx.SetHeader("Content-Type", "application/json; charset=utf-8")
+		ctx.SetStatusCode(http.StatusOK)
+		ctx.SetData(spec)
+	})
+	h.GET(uiPath, func(ctx *Context) {
+		var buf bytes.Buffer
+		if err := openAPIPageTemplate.Execute(&buf, map[string]string{
+			"Title":   info.Title,
+			"SpecURL": specPath,
+		}); err != nil {
+			panic(err)
+		}
+		ctx.HTML(http.StatusOK, buf.String())
+	})
+}
+
+// openAPIPath /user/:id 转换成 /user/{id}
+func openAPIPath(pattern string) string {
+	parts := strings.Split(pattern, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// operationID GET /user/:id 生成 getUserById
+func operationID(method string, pattern string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	for _, part := range strings.Split(pattern, "/") {
+		if part == "" {
+			continue
+		}
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			sb.WriteString("By")
+			part = part[1:]
+		}
+		for _, word := range strings.FieldsFunc(part, func(r rune) bool {
+			return r == '-' || r == '_' || r == '.'
+		}) {
+			sb.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	if pattern == "/" {
+		sb.WriteString("Root")
+	}
+	return sb.String()
+}
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	httpErrorType     = reflect.TypeOf(HTTPError{})
+	schemaNameRegexp  = regexp.MustCompile(`[\w./-]*[./]`)
+)
+
+type openAPIGenerator struct {
+	// schemas 放到components中的结构
+	schemas map[string]*OpenAPISchema
+	// names 类型在components中的名字，同名的类型会加上序号区分
+	names map[reflect.Type]string
+}
+
+func (g *openAPIGenerator) operation(route RouteInfo) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		OperationID: operationID(route.Method, route.Pattern),
+		Responses:   map[string]*OpenAPIResponse{},
+	}
+	for _, part := range strings.Split(route.Pattern, "/") {
+		if part != "" && !strings.HasPrefix(part, ":") && !strings.HasPrefix(part, "*") {
+			op.Tags = []string{part}
+			break
+		}
+	}
+
+	sig, typed := signatureOf(route.handleFunc)
+	var req reflect.Type
+	if typed {
+		req = sig.req
+	}
+	// 路径参数以路由为准，Typed视图函数中声明了的话使用声明的类型
+	pathFields := map[string]reflect.StructField{}
+	queryFields := make([]reflect.StructField, 0, 4)
+	if req != nil && req.Kind() == reflect.Struct {
+		eachField(req, func(field reflect.StructField) {
+			if name, ok := tagName(field, "path"); ok {
+				pathFields[name] = field
+			} else if _, ok := tagName(field, "query"); ok {
+				queryFields = append(queryFields, field)
+			}
+		})
+	}
+	for _, part := range strings.Split(route.Pattern, "/") {
+		if !strings.HasPrefix(part, ":") && !strings.HasPrefix(part, "*") {
+			continue
+		}
+		param := &OpenAPIParameter{Name: part[1:], In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"}}
+		if field, ok := pathFields[param.Name]; ok {
+			param.Schema = g.fieldSchema(field)
+		}
+		op.Parameters = append(op.Parameters, param)
+	}
+	for _, field := range queryFields {
+		name, _ := tagName(field, "query")
+		op.Parameters = append(op.Parameters, &OpenAPIParameter{
+			Name:     name,
+			In:       "query",
+			Required: hasRule(field, "required"),
+			Schema:   g.fieldSchema(field),
+		})
+	}
+
+	if !typed {
+		op.Responses["200"] = &OpenAPIResponse{Description: "OK"}
+		return op
+	}
+
+	switch route.Method {
+	case http.MethodGet, http.MethodHead:
+	default:
+		if body := g.bodySchema(req, len(pathFields)+len(queryFields) > 0); body != nil {
+			op.RequestBody = &OpenAPIRequestBody{
+				Required: true,
+				Content:  map[string]*OpenAPIMediaType{MIMEJSON: {Schema: body}},
+			}
+		}
+	}
+
+	resp := g.schemaOf(sig.resp)
+	op.Responses["200"] = &OpenAPIResponse{
+		Description: "OK",
+		Content: map[string]*OpenAPIMediaType{
+			MIMEJSON:    {Schema: resp},
+			MIMEXML:     {Schema: resp},
+			MIMEYAML:    {Schema: resp},
+			MIMEMsgpack: {Schema: resp},
+		},
+	}
+	op.Responses["204"] = &OpenAPIResponse{Description: "No Content"}
+	errResp := func(description string) *OpenAPIResponse {
+		return &OpenAPIResponse{
+			Description: description,
+			Content:     map[string]*OpenAPIMediaType{MIMEJSON: {Schema: g.schemaOf(httpErrorType)}},
+		}
+	}
+	op.Responses["400"] = errResp("参数解析失败")
+	op.Responses["422"] = errResp("参数校验失败")
+	op.Responses["default"] = errResp("错误")
+	return op
+}
+
+// bodySchema 请求体的结构
+// 有路径参数或者查询参数的时候，只保留剩下的字段，直接内联在文档中
+func (g *openAPIGenerator) bodySchema(req reflect.Type, hasParams bool) *OpenAPISchema {
+	if req == nil {
+		return nil
+	}
+	if req.Kind() != reflect.Struct || !hasParams {
+		return g.schemaOf(req)
+	}
+	schema := g.structSchema(req, true)
+	if len(schema.Properties) == 0 {
+		return nil
+	}
+	return schema
+}
+
+// schemaOf 类型对应的结构，有名字的结构体放到components中然后引用
+func (g *openAPIGenerator) schemaOf(t reflect.Type) *OpenAPISchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	case t == fileHeaderType:
+		return &OpenAPISchema{Type: "string", Format: "binary"}
+	case t.Kind() != reflect.String && reflect.PointerTo(t).Implements(textMarshalerType):
+		return &OpenAPISchema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		return &OpenAPISchema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t, false)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.schemaName(t)
+			g.names[t] = name
+			// 先占位，递归引用自己的结构体才不会死循环
+			g.schemas[name] = &OpenAPISchema{}
+			*g.schemas[name] = *g.structSchema(t, false)
+		}
+		return &OpenAPISchema{Ref: "#/components/schemas/" + name}
+	}
+	// interface 之类的类型，任意值都可以
+	return &OpenAPISchema{}
+}
+
+// schemaName 泛型类型 Page[github.com/x/model.User] 转换成 Page_User
+func (g *openAPIGenerator) schemaName(t reflect.Type) string {
+	name := schemaNameRegexp.ReplaceAllString(t.Name(), "")
+	name = strings.Trim(strings.NewReplacer("[", "_", "]", "", ",", "_", "*", "").Replace(name), "_")
+	res := name
+	for i := 2; ; i++ {
+		if _, ok := g.schemas[res]; !ok {
+			return res
+		}
+		res = fmt.Sprintf("%s%d", name, i)
+	}
+}
+
+// structSchema 结构体的结构，字段名以json tag为准
+// skipParams 为true时跳过路径参数和查询参数
+func (g *openAPIGenerator) structSchema(t reflect.Type, skipParams bool) *OpenAPISchema {
+	schema := &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{}}
+	eachField(t, func(field reflect.StructField) {
+		if skipParams {
+			if _, ok := tagName(field, "path"); ok {
+				return
+			}
+			if _, ok := tagName(field, "query"); ok {
+				return
+			}
+		}
+		name, ok := tagName(field, "json")
+		if !ok {
+			name = field.Name
+		}
+		if name == "-" {
+			return
+		}
+		schema.Properties[name] = g.fieldSchema(field)
+		if hasRule(field, "required") {
+			schema.Required = append(schema.Required, name)
+		}
+	})
+	return schema
+}
+
+// fieldSchema 字段的结构，带上validate tag中的约束
+func (g *openAPIGenerator) fieldSchema(field reflect.StructField) *OpenAPISchema {
+	schema := g.schemaOf(field.Type)
+	rules, ok := field.Tag.Lookup("validate")
+	if !ok || schema.Ref != "" {
+		return schema
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "min", "max":
+			limit, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			n := int(limit)
+			switch schema.Type {
+			case "integer", "number":
+				if name == "min" {
+					schema.Minimum = &limit
+				} else {
+					schema.Maximum = &limit
+				}
+			case "string":
+				if name == "min" {
+					schema.MinLength = &n
+				} else {
+					schema.MaxLength = &n
+				}
+			case "array":
+				if name == "min" {
+					schema.MinItems = &n
+				} else {
+					schema.MaxItems = &n
+				}
+			}
+		case "oneof":
+			for _, option := range strings.Fields(param) {
+				if schema.Type == "integer" || schema.Type == "number" {
+					if val, err := strconv.ParseFloat(option, 64); err == nil {
+						schema.Enum = append(schema.Enum, val)
+						continue
+					}
+				}
+				schema.Enum = append(schema.Enum, option)
+			}
+		}
+	}
+	return schema
+}
+
+// eachField 遍历结构体导出的字段，匿名嵌套的结构体展开
+func eachField(t reflect.Type, fn func(field reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if _, ok := field.Tag.Lookup("json"); !ok && ft.Kind() == reflect.Struct {
+				eachField(ft, fn)
+				continue
+			}
+		}
+		if field.IsExported() {
+			fn(field)
+		}
+	}
+}
+
+// tagName 字段tag中的名字，没有这个tag或者是 "-" 返回false
+func tagName(field reflect.StructField, tag string) (string, bool) {
+	value, ok := field.Tag.Lookup(tag)
+	if !ok {
+		return "", false
+	}
+	name, _, _ := strings.Cut(value, ",")
+	if name == "-" {
+		return name, tag == "json"
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+func hasRule(field reflect.StructField, rule string) bool {
+	for _, r := range strings.Split(field.Tag.Get("validate"), ",") {
+		if name, _, _ := strings.Cut(strings.TrimSpace(r), "="); name == rule {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package bilibili_http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type openAPIList[T any] struct {
+	Total int `json:"total"`
+	Items []T `json:"items"`
+}
+
+type openAPIUser struct {
+	ID       int64          `json:"id"`
+	Name     string         `json:"name" validate:"required,min=3,max=20"`
+	Role     string         `json:"role,omitempty" validate:"oneof=admin user"`
+	Created  time.Time      `json:"created"`
+	Friends  []*openAPIUser `json:"friends,omitempty"`
+	Password string         `json:"-"`
+}
+
+type openAPIListReq struct {
+	Group int `path:"group"`
+	Page  int `query:"page" validate:"min=1"`
+}
+
+// TestOpenAPI 测试根据路由生成文档
+func TestOpenAPI(t *testing.T) {
+	h := NewHTTP()
+	v1 := h.Group("/v1")
+	v1.GET("/group/:group/users", Typed(func(ctx *Context, req *openAPIListReq) (*openAPIList[openAPIUser], error) {
+		return nil, nil
+	}))
+	v1.POST("/user", Typed(func(ctx *Context, req *openAPIUser) (*openAPIUser, error) {
+		return req, nil
+	}))
+	v1.DELETE("/user/:id", func(ctx *Context) {})
+	doc := h.OpenAPI(OpenAPIInfo{Title: "用户服务", Version: "1.0.0"})
+
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+	assert.Len(t, doc.Paths, 3)
+
+	list := doc.Paths["/v1/group/{group}/users"]["get"]
+	require.NotNil(t, list)
+	assert.Equal(t, "getV1GroupByGroupUsers", list.OperationID)
+	assert.Equal(t, []string{"v1"}, list.Tags)
+	assert.Nil(t, list.RequestBody)
+	require.Len(t, list.Parameters, 2)
+	assert.Equal(t, &OpenAPIParameter{Name: "group", In: "path", Required: true, Schema: &OpenAPISchema{Type: "integer", Format: "int64"}}, list.Parameters[0])
+	one := float64(1)
+	assert.Equal(t, &OpenAPIParameter{Name: "page", In: "query", Schema: &OpenAPISchema{Type: "integer", Format: "int64", Minimum: &one}}, list.Parameters[1])
+	assert.Equal(t, "#/components/schemas/openAPIList_openAPIUser", list.Responses["200"].Content[MIMEJSON].Schema.Ref)
+
+	create := doc.Paths["/v1/user"]["post"]
+	require.NotNil(t, create)
+	assert.Equal(t, "#/components/schemas/openAPIUser", create.RequestBody.Content[MIMEJSON].Schema.Ref)
+	assert.Equal(t, "#/components/schemas/HTTPError", create.Responses["422"].Content[MIMEJSON].Schema.Ref)
+
+	user := doc.Components.Schemas["openAPIUser"]
+	require.NotNil(t, user)
+	assert.Equal(t, []string{"name"}, user.Required)
+	assert.NotContains(t, user.Properties, "-")
+	assert.NotContains(t, user.Properties, "Password")
+	assert.Equal(t, &OpenAPISchema{Type: "string", Format: "date-time"}, user.Properties["created"])
+	assert.Equal(t, []any{"admin", "user"}, user.Properties["role"].Enum)
+	three, twenty := 3, 20
+	assert.Equal(t, &three, user.Properties["name"].MinLength)
+	assert.Equal(t, &twenty, user.Properties["name"].MaxLength)
+	// 递归引用自己
+	assert.Equal(t, "#/components/schemas/openAPIUser", user.Properties["friends"].Items.Ref)
+
+	del := doc.Paths["/v1/user/{id}"]["delete"]
+	require.NotNil(t, del)
+	assert.Equal(t, []*OpenAPIParameter{{Name: "id", In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"}}}, del.Parameters)
+	assert.Equal(t, map[string]*OpenAPIResponse{"200": {Description: "OK"}}, del.Responses)
+
+	// 导出到文件
+	name := filepath.Join(t.TempDir(), "openapi.json")
+	require.NoError(t, doc.SaveFile(name))
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	var exported map[string]any
+	require.NoError(t, json.Unmarshal(data, &exported))
+	assert.Equal(t, "用户服务", exported["info"].(map[string]any)["title"])
+}
+
+// TestServeOpenAPI 测试文档和查看文档的页面
+func TestServeOpenAPI(t *testing.T) {
+	h := NewHTTP()
+	h.ServeOpenAPI("/openapi.json", "/docs", OpenAPIInfo{Title: "用户服务", Version: "1.0.0"})
+	// 在ServeOpenAPI之后注册的路由也会出现在文档中
+	h.GET("/user/:id", func(ctx *Context) {})
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json; charset=utf-8", recorder.Header().Get("Content-Type"))
+	var doc OpenAPIDocument
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &doc))
+	assert.Len(t, doc.Paths, 1)
+	assert.Contains(t, doc.Paths, "/user/{id}")
+
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `data-spec="/openapi.json"`)
+	assert.Contains(t, recorder.Body.String(), "<title>用户服务</title>")
+	assert.NotContains(t, recorder.Body.String(), "<script src=")
+}
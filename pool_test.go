@@ -0,0 +1,83 @@
+package bilibili_http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestContextPool 测试复用的上下文不会带上上一个请求的数据
+func TestContextPool(t *testing.T) {
+	h := NewHTTP()
+	var copied *Context
+	h.GET("/user/:id", func(ctx *Context) {
+		_, err := ctx.Params("name")
+		assert.Error(t, err)
+		_, ok := ctx.Get("user")
+		assert.False(t, ok)
+		assert.Empty(t, ctx.ResponseHeader())
+		assert.Empty(t, ctx.Errors())
+		ctx.Set("user", "tom")
+		ctx.SetHeader("X-User", "tom")
+		ctx.Error(errors.New("db down"))
+		copied = ctx.Copy()
+		ctx.TEXT(http.StatusOK, "tom")
+	})
+	h.GET("/user/:id/:name", func(ctx *Context) {
+		ctx.TEXT(http.StatusOK, "jerry")
+	})
+	for _, path := range []string{"/user/1/jerry", "/user/1", "/user/2"} {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	}
+
+	// 复制出来的上下文在视图函数返回之后依然可以使用
+	id, err := copied.Params("id")
+	require.NoError(t, err)
+	assert.Equal(t, "2", id)
+	assert.Equal(t, "tom", copied.GetString("user"))
+	assert.Equal(t, "tom", copied.ResponseHeader().Get("X-User"))
+	assert.Len(t, copied.Errors(), 1)
+	assert.Equal(t, "/user/2", copied.Request().URL.Path)
+}
+
+// BenchmarkServeHTTP 每个请求的内存分配情况
+// go test -run '^$' -bench ServeHTTP -benchmem
+// 复用上下文之前：1088 B/op 13 allocs/op
+// 复用上下文之后：144 B/op 8 allocs/op
+// 预先组合调用链之后：64 B/op 3 allocs/op
+func BenchmarkServeHTTP(b *testing.B) {
+	h := NewHTTP()
+	h.GET("/user/:id", func(ctx *Context) {
+		id, _ := ctx.Params("id")
+		ctx.SetHeader("X-User", id)
+		ctx.SetStatusCode(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+	w := &discardResponseWriter{header: http.Header{}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.ServeHTTP(w, req)
+	}
+}
+
+// discardResponseWriter 丢弃所有响应，避免httptest.ResponseRecorder自身的分配干扰结果
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(int) {}
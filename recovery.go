@@ -0,0 +1,142 @@
+package bilibili_http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// PanicReport 一次panic的报告
+type PanicReport struct {
+	Time time.Time
+	// Err panic的值
+	Err any
+	// Stack panic时的调用栈，连接断开的情况下为空
+	Stack []byte
+	// Method 请求方法
+	Method string
+	// Path 请求路径
+	Path string
+	// Route 匹配到的路由
+	Route string
+	// RequestID 请求ID，没有的话为空
+	RequestID string
+	// BrokenPipe 客户端已经断开连接
+	BrokenPipe bool
+}
+
+func newPanicReport(ctx *Context, err any) *PanicReport {
+	report := &PanicReport{
+		Time:       time.Now(),
+		Err:        err,
+		Method:     ctx.Method,
+		Path:       ctx.Pattern,
+		Route:      ctx.Route(),
+		RequestID:  ctx.RequestID(),
+		BrokenPipe: isBrokenPipe(err),
+	}
+	if !report.BrokenPipe {
+		report.Stack = debug.Stack()
+	}
+	return report
+}
+
+// isBrokenPipe 客户端断开连接导致的写入失败，这种情况没有必要打印调用栈
+func isBrokenPipe(err any) bool {
+	e, ok := err.(error)
+	if !ok {
+		return false
+	}
+	if errors.Is(e, syscall.EPIPE) || errors.Is(e, syscall.ECONNRESET) {
+		return true
+	}
+	msg := strings.ToLower(e.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
+
+// Error panic的值转换成的错误信息
+func (r *PanicReport) Error() string {
+	return fmt.Sprint(r.Err)
+}
+
+// String 方便直接输出到日志中的格式
+func (r *PanicReport) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[Recovery] %s panic: %v\n", r.Time.Format(time.RFC3339), r.Err))
+	sb.WriteString(fmt.Sprintf("%s %s", r.Method, r.Path))
+	if r.Route != "" {
+		sb.WriteString(fmt.Sprintf(" (%s)", r.Route))
+	}
+	if r.RequestID != "" {
+		sb.WriteString(" request_id=" + r.RequestID)
+	}
+	if r.BrokenPipe {
+		sb.WriteString(" broken pipe")
+	}
+	if len(r.Stack) > 0 {
+		sb.WriteString("\n")
+		sb.Write(r.Stack)
+	}
+	return sb.String()
+}
+
+// RecoveryHandler 处理recovery捕获到的panic，负责记录日志和给客户端响应
+type RecoveryHandler func(ctx *Context, report *PanicReport)
+
+// Recovery 兜底的错误恢复，内置的recovery就是 Recovery(DefaultRecoveryHandler)
+// http.ErrAbortHandler 继续往上抛，其他的panic生成报告、中断请求之后交给handler处理
+// 需要钩子或者其他响应格式的时候，使用 middlewares/recovery 包
+func Recovery(handler RecoveryHandler) MiddlewareHandleFunc {
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			defer func() {
+				err := recover()
+				if err == nil {
+					return
+				}
+				// 标准库约定的中断响应的方式，继续往上抛，由http.Server静默地断开连接
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				report := newPanicReport(ctx, err)
+				ctx.Abort()
+				handler(ctx, report)
+			}()
+			next(ctx)
+		}
+	}
+}
+
+// DefaultRecoveryHandler 记录日志并响应500
+// 客户端已经断开连接的话写什么都收不到，只记录一条警告
+func DefaultRecoveryHandler(ctx *Context, report *PanicReport) {
+	LogPanic(ctx, report)
+	if report.BrokenPipe {
+		return
+	}
+	RenderPanic(ctx, report)
+}
+
+// LogPanic 记录panic的报告，连接断开的情况只记录一条警告
+// ctx.Logger() 已经带上了请求ID，这里不需要再加
+func LogPanic(ctx *Context, report *PanicReport) {
+	fields := []Field{F("error", report.Err)}
+	if report.BrokenPipe {
+		ctx.Logger().Warn("客户端断开连接", fields...)
+		return
+	}
+	fields = append(fields, F("stack", string(report.Stack)))
+	ctx.Logger().Error("请求处理过程中发生panic", fields...)
+}
+
+// RenderPanic 响应纯文本的500
+// 视图函数在panic之前可能已经设置了Content-Type，需要去掉，由Commit重新推断
+func RenderPanic(ctx *Context, report *PanicReport) {
+	ctx.DelHeader("Content-Type")
+	ctx.SetStatusCode(http.StatusInternalServerError)
+	ctx.SetData([]byte("Server Internal Error, Please Try Again Later!"))
+}
@@ -0,0 +1,112 @@
+package bilibili_http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRecovery 测试内置的recovery
+func TestRecovery(t *testing.T) {
+	brokenPipe := &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}
+	testCases := []struct {
+		name       string
+		panicValue any
+		// contentType 视图函数panic之前设置的Content-Type
+		contentType string
+
+		wantCode        int
+		wantContentType string
+		wantBody        string
+		wantLog         string
+		notWantLog      string
+	}{
+		{
+			// 视图函数panic之前设置的Content-Type不能留在500的响应上
+			name:            "content type",
+			panicValue:      "db down",
+			contentType:     MIMEJSON,
+			wantCode:        http.StatusInternalServerError,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "Server Internal Error, Please Try Again Later!",
+			wantLog:         `level=ERROR msg=请求处理过程中发生panic method=GET path=/user/1 route=/user/:id error="db down" stack=`,
+		},
+		{
+			// 客户端已经断开了，不响应，只记录一条警告，也不需要调用栈
+			name:       "broken pipe",
+			panicValue: brokenPipe,
+			wantCode:   http.StatusOK,
+			wantLog:    `level=WARN msg=客户端断开连接 method=GET path=/user/1 route=/user/:id error="write tcp: write: broken pipe"`,
+			notWantLog: "stack=",
+		},
+		{
+			name:       "connection reset",
+			panicValue: fmt.Errorf("read: %w", syscall.ECONNRESET),
+			wantCode:   http.StatusOK,
+			wantLog:    `level=WARN msg=客户端断开连接`,
+			notWantLog: "stack=",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := NewHTTP(WithLogger(NewStdLogger(buf, LevelDebug)))
+			h.GET("/user/:id", func(ctx *Context) {
+				if tc.contentType != "" {
+					ctx.SetHeader("Content-Type", tc.contentType)
+				}
+				panic(tc.panicValue)
+			})
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user/1", nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantContentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Contains(t, buf.String(), tc.wantLog)
+			if tc.notWantLog != "" {
+				assert.NotContains(t, buf.String(), tc.notWantLog)
+			}
+		})
+	}
+}
+
+// TestRecoveryAbortHandler 测试 http.ErrAbortHandler 继续往上抛，交给http.Server断开连接
+func TestRecoveryAbortHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHTTP(WithLogger(NewStdLogger(buf, LevelDebug)))
+	h.GET("/download", func(ctx *Context) {
+		panic(http.ErrAbortHandler)
+	})
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/download", nil))
+	})
+	assert.NotContains(t, buf.String(), "panic")
+}
+
+// TestIsBrokenPipe 测试识别客户端断开连接的错误
+func TestIsBrokenPipe(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value any
+		want  bool
+	}{
+		{name: "epipe", value: fmt.Errorf("write: %w", syscall.EPIPE), want: true},
+		{name: "reset", value: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, want: true},
+		{name: "message", value: errors.New("write tcp 127.0.0.1:8080: broken pipe"), want: true},
+		{name: "other error", value: errors.New("db down"), want: false},
+		{name: "string", value: "broken pipe", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, isBrokenPipe(tc.value))
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package bilibili_http
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// 响应中常用的Content-Type
+const (
+	MIMEHTML       = "text/html"
+	MIMEPlain      = "text/plain"
+	MIMEJavaScript = "application/javascript"
+)
+
+// Render 响应数据的渲染器
+// 每一种响应格式对应一个Render，Context.Respond负责把渲染结果写到上下文中
+type Render interface {
+	// ContentType 响应的Content-Type
+	ContentType() string
+	// Render 将数据渲染到w中
+	Render(w io.Writer) error
+}
+
+// JSONRender 响应JSON格式数据
+type JSONRender struct {
+	Data any
+}
+
+func (JSONRender) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (r JSONRender) Render(w io.Writer) error {
+	res, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(res)
+	return err
+}
+
+// IndentedJSONRender 响应带缩进的JSON格式数据，方便人看，但是体积更大
+type IndentedJSONRender struct {
+	Data any
+}
+
+func (IndentedJSONRender) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (r IndentedJSONRender) Render(w io.Writer) error {
+	res, err := json.MarshalIndent(r.Data, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(res)
+	return err
+}
+
+// jsonpCallbackRegexp 合法的JSONP回调函数名，防止通过callback参数注入脚本
+var jsonpCallbackRegexp = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*(\.[a-zA-Z_$][a-zA-Z0-9_$]*)*$`)
+
+// JSONPRender 响应JSONP格式数据：callback(data);
+// Callback为空时退化成普通的JSON
+type JSONPRender struct {
+	Callback string
+	Data     any
+}
+
+func (r JSONPRender) ContentType() string {
+	if r.Callback == "" {
+		return JSONRender{}.ContentType()
+	}
+	return MIMEJavaScript + "; charset=utf-8"
+}
+
+func (r JSONPRender) Render(w io.Writer) error {
+	if r.Callback == "" {
+		return JSONRender{Data: r.Data}.Render(w)
+	}
+	if !jsonpCallbackRegexp.MatchString(r.Callback) {
+		return fmt.Errorf("web: 非法的JSONP回调函数名 [%s]", r.Callback)
+	}
+	res, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s(%s);", r.Callback, res)
+	return err
+}
+
+// defaultSecureJSONPrefix 防止JSON劫持的默认前缀
+const defaultSecureJSONPrefix = "while(1);"
+
+// SecureJSONRender 在JSON前面加上前缀，防止JSON劫持
+// 前端需要先去掉前缀再解析
+type SecureJSONRender struct {
+	// Prefix 为空时使用 while(1);
+	Prefix string
+	Data   any
+}
+
+func (SecureJSONRender) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (r SecureJSONRender) Render(w io.Writer) error {
+	res, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	prefix := r.Prefix
+	if prefix == "" {
+		prefix = defaultSecureJSONPrefix
+	}
+	if _, err = io.WriteString(w, prefix); err != nil {
+		return err
+	}
+	_, err = w.Write(res)
+	return err
+}
+
+// XMLRender 响应XML格式数据
+type XMLRender struct {
+	Data any
+}
+
+func (XMLRender) ContentType() string {
+	return MIMEXML + "; charset=utf-8"
+}
+
+func (r XMLRender) Render(w io.Writer) error {
+	return xml.NewEncoder(w).Encode(r.Data)
+}
+
+// YAMLRender 响应YAML格式数据
+type YAMLRender struct {
+	Data any
+}
+
+func (YAMLRender) ContentType() string {
+	return MIMEYAML + "; charset=utf-8"
+}
+
+func (r YAMLRender) Render(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	if err := encoder.Encode(r.Data); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// MsgpackRender 响应MessagePack格式数据，字段名和JSON保持一致
+type MsgpackRender struct {
+	Data any
+}
+
+func (MsgpackRender) ContentType() string {
+	return MIMEMsgpack
+}
+
+func (r MsgpackRender) Render(w io.Writer) error {
+	encoder := msgpack.NewEncoder(w)
+	encoder.SetCustomStructTag("json")
+	return encoder.Encode(r.Data)
+}
+
+// TextRender 响应纯文本数据
+type TextRender struct {
+	Text string
+}
+
+func (TextRender) ContentType() string {
+	return MIMEPlain + "; charset=utf-8"
+}
+
+func (r TextRender) Render(w io.Writer) error {
+	_, err := io.WriteString(w, r.Text)
+	return err
+}
+
+// HTMLRender 响应HTML数据
+type HTMLRender struct {
+	HTML string
+}
+
+func (HTMLRender) ContentType() string {
+	return MIMEHTML + "; charset=utf-8"
+}
+
+func (r HTMLRender) Render(w io.Writer) error {
+	_, err := io.WriteString(w, r.HTML)
+	return err
+}
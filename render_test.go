@@ -0,0 +1,172 @@
+package bilibili_http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNegotiate 测试根据Accept选择响应格式
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/json; charset=utf-8", "application/xml; charset=utf-8", "text/html"}
+	testCases := []struct {
+		name   string
+		accept string
+
+		wantIdx int
+	}{
+		{
+			name:    "empty accept",
+			wantIdx: 0,
+		},
+		{
+			name:    "exact",
+			accept:  "application/xml",
+			wantIdx: 1,
+		},
+		{
+			name:    "q value",
+			accept:  "application/json;q=0.5, application/xml;q=0.8",
+			wantIdx: 1,
+		},
+		{
+			name:    "wildcard subtype",
+			accept:  "text/*",
+			wantIdx: 2,
+		},
+		{
+			name:    "any keeps offer order",
+			accept:  "*/*",
+			wantIdx: 0,
+		},
+		{
+			name:    "more specific range wins",
+			accept:  "application/*;q=0.9, application/json;q=0.1",
+			wantIdx: 1,
+		},
+		{
+			name:    "q zero excludes",
+			accept:  "application/json;q=0, */*;q=0.1",
+			wantIdx: 1,
+		},
+		{
+			name:    "browser",
+			accept:  "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			wantIdx: 2,
+		},
+		{
+			name:    "not acceptable",
+			accept:  "image/png",
+			wantIdx: -1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantIdx, negotiate(tc.accept, offers))
+		})
+	}
+}
+
+// TestContextRespond 测试各种渲染器的响应结果
+func TestContextRespond(t *testing.T) {
+	data := H{"name": "tom"}
+	testCases := []struct {
+		name    string
+		url     string
+		accept  string
+		handler HandleFunc
+
+		wantCode        int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "json",
+			url:             "/",
+			handler:         func(ctx *Context) { ctx.JSON(http.StatusOK, data) },
+			wantCode:        http.StatusOK,
+			wantContentType: "application/json; charset=utf-8",
+			wantBody:        `{"name":"tom"}`,
+		},
+		{
+			name:            "jsonp",
+			url:             "/?callback=cb",
+			handler:         func(ctx *Context) { ctx.JSONP(http.StatusOK, data) },
+			wantCode:        http.StatusOK,
+			wantContentType: "application/javascript; charset=utf-8",
+			wantBody:        `cb({"name":"tom"});`,
+		},
+		{
+			// 客户端传了不合法的回调函数名，不能当成服务端的错误
+			name:            "jsonp invalid callback",
+			url:             "/?callback=alert(1)",
+			handler:         func(ctx *Context) { ctx.JSONP(http.StatusOK, data) },
+			wantCode:        http.StatusBadRequest,
+			wantContentType: "application/json; charset=utf-8",
+			wantBody:        `{"code":400,"msg":"非法的JSONP回调函数名"}`,
+		},
+		{
+			name:            "secure json",
+			url:             "/",
+			handler:         func(ctx *Context) { ctx.SecureJSON(http.StatusOK, []int{1, 2}) },
+			wantCode:        http.StatusOK,
+			wantContentType: "application/json; charset=utf-8",
+			wantBody:        `while(1);[1,2]`,
+		},
+		{
+			name:            "yaml",
+			url:             "/",
+			handler:         func(ctx *Context) { ctx.YAML(http.StatusCreated, data) },
+			wantCode:        http.StatusCreated,
+			wantContentType: "application/x-yaml; charset=utf-8",
+			wantBody:        "name: tom\n",
+		},
+		{
+			name:            "text",
+			url:             "/",
+			handler:         func(ctx *Context) { ctx.TEXT(http.StatusOK, "hello") },
+			wantCode:        http.StatusOK,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "hello",
+		},
+		{
+			name:   "negotiate",
+			url:    "/",
+			accept: "text/plain;q=0.5, application/json;q=0.1",
+			handler: func(ctx *Context) {
+				ctx.Negotiate(http.StatusOK, JSONRender{Data: data}, TextRender{Text: "tom"})
+			},
+			wantCode:        http.StatusOK,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "tom",
+		},
+		{
+			name:   "not acceptable",
+			url:    "/",
+			accept: "image/png",
+			handler: func(ctx *Context) {
+				ctx.Negotiate(http.StatusOK, JSONRender{Data: data}, XMLRender{Data: data})
+			},
+			wantCode:        http.StatusNotAcceptable,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "Not Acceptable",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHTTP()
+			h.GET("/", tc.handler)
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantContentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
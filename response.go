@@ -0,0 +1,115 @@
+package bilibili_http
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Committed 响应是否已经写入到ResponseWriter中
+// 写入之后再修改状态码、响应头和响应体都不会生效了
+func (c *Context) Committed() bool {
+	return c.committed
+}
+
+// Commit 把缓冲的状态码、响应头和响应体写入ResponseWriter，只会写一次
+// flush中间件在请求处理完之后调用，ServeHTTP最后也会调用一次兜底
+// 没有设置Content-Type的时候根据响应体推断，没有设置Content-Length的时候自动加上
+func (c *Context) Commit() {
+	if c.committed {
+		return
+	}
+	c.committed = true
+	allowBody := bodyAllowed(c.status)
+	if allowBody && len(c.data) > 0 && c.header.Get("Content-Type") == "" {
+		c.header.Set("Content-Type", http.DetectContentType(c.data))
+	}
+	// HEAD请求没有响应体，这时候的长度由视图函数自己决定，例如 ServeContent
+	if allowBody && c.Method != http.MethodHead && c.header.Get("Content-Length") == "" {
+		c.header.Set("Content-Length", strconv.Itoa(len(c.data)))
+	}
+	// 写入响应头，必须在写入状态码之前，否则不会生效
+	for key, values := range c.header {
+		c.response.Header()[key] = values
+	}
+	// 写入状态码
+	c.response.WriteHeader(c.status)
+	// 写入响应体，HEAD请求的响应体net/http也会丢掉，这里直接不写
+	if allowBody && c.Method != http.MethodHead && len(c.data) > 0 {
+		n, _ := c.response.Write(c.data)
+		c.size += n
+	}
+}
+
+// ResponseSize 响应体的大小
+// 已经提交的响应是实际写入的字节数，包括流式响应和文件；还在缓冲中的是提交之后将要写入的字节数
+func (c *Context) ResponseSize() int {
+	if c.committed {
+		return c.size
+	}
+	if !bodyAllowed(c.status) || c.Method == http.MethodHead {
+		return 0
+	}
+	return len(c.data)
+}
+
+// Stream 流式响应，例如 Server-Sent Events 或者很大的文件
+// 先写入状态码、响应头和已经设置的响应体，然后反复调用step，每次调用之后都把数据推送给客户端
+// step返回false或者客户端断开连接的时候结束，返回值表示是不是因为客户端断开连接而结束的
+// 流式响应不会设置Content-Length，flush中间件的转换函数也不会再处理这个响应
+//
+//	ctx.SetHeader("Content-Type", "text/event-stream")
+//	ctx.Stream(func(w io.Writer) bool {
+//		msg, ok := <-messages
+//		if !ok {
+//			return false
+//		}
+//		fmt.Fprintf(w, "data: %s\n\n", msg)
+//		return true
+//	})
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	if c.committed {
+		panic("web: 响应已经写入，不能再使用流式响应")
+	}
+	c.commitHeader()
+	// 通过responseStreamer写入，统计写入的字节数
+	w := &responseStreamer{ctx: c}
+	if len(c.data) > 0 {
+		_, _ = w.Write(c.data)
+	}
+	flusher, _ := c.response.(http.Flusher)
+	done := c.request.Context().Done()
+	for {
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-done:
+			return true
+		default:
+		}
+		if !step(w) {
+			return false
+		}
+	}
+}
+
+// commitHeader 只写入状态码和响应头，响应体由调用方直接写到ResponseWriter中
+func (c *Context) commitHeader() {
+	c.committed = true
+	for key, values := range c.header {
+		c.response.Header()[key] = values
+	}
+	c.response.WriteHeader(c.status)
+}
+
+// bodyAllowed 1xx、204和304的响应不能有响应体
+func bodyAllowed(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package bilibili_http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestContextCommit 测试自动设置Content-Type和Content-Length
+func TestContextCommit(t *testing.T) {
+	testCases := []struct {
+		name       string
+		method     string
+		handleFunc HandleFunc
+
+		wantCode          int
+		wantBody          string
+		wantContentType   string
+		wantContentLength string
+	}{
+		{
+			name:   "sniff",
+			method: http.MethodGet,
+			handleFunc: func(ctx *Context) {
+				ctx.SetData([]byte("<html><body>hello</body></html>"))
+			},
+			wantCode:          http.StatusOK,
+			wantBody:          "<html><body>hello</body></html>",
+			wantContentType:   "text/html; charset=utf-8",
+			wantContentLength: "31",
+		},
+		{
+			name:   "keep content type",
+			method: http.MethodGet,
+			handleFunc: func(ctx *Context) {
+				ctx.JSON(http.StatusCreated, map[string]int{"id": 1})
+			},
+			wantCode:          http.StatusCreated,
+			wantBody:          `{"id":1}`,
+			wantContentType:   "application/json; charset=utf-8",
+			wantContentLength: "8",
+		},
+		{
+			name:   "empty body",
+			method: http.MethodGet,
+			handleFunc: func(ctx *Context) {
+			},
+			wantCode:          http.StatusOK,
+			wantContentLength: "0",
+		},
+		{
+			name:   "no content",
+			method: http.MethodGet,
+			handleFunc: func(ctx *Context) {
+				ctx.SetStatusCode(http.StatusNoContent)
+				ctx.SetData([]byte("ignored"))
+			},
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:   "head",
+			method: http.MethodHead,
+			handleFunc: func(ctx *Context) {
+			},
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHTTP()
+			h.addRouter(tc.method, "/", tc.handleFunc)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(tc.method, "/", nil))
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+			assert.Equal(t, tc.wantContentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tc.wantContentLength, recorder.Header().Get("Content-Length"))
+		})
+	}
+}
+
+// TestContextStream 测试流式响应，写出去之后flush中间件不会再写一次
+func TestContextStream(t *testing.T) {
+	h := NewHTTP()
+	h.GET("/events", func(ctx *Context) {
+		ctx.SetHeader("Content-Type", "text/event-stream")
+		i := 0
+		ctx.Stream(func(w io.Writer) bool {
+			_, _ = fmt.Fprintf(w, "data: %d\n\n", i)
+			i++
+			return i < 3
+		})
+		assert.True(t, ctx.Committed())
+		assert.Equal(t, 27, ctx.ResponseSize())
+		// 已经写出去了，不会再生效
+		ctx.SetData([]byte("ignored"))
+	})
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/events", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.True(t, recorder.Flushed)
+	assert.Equal(t, "data: 0\n\ndata: 1\n\ndata: 2\n\n", recorder.Body.String())
+	assert.Equal(t, "", recorder.Header().Get("Content-Length"))
+}
+
+// TestContextResponseSize 测试响应体的大小，缓冲的响应和直接写出去的响应都要准确
+func TestContextResponseSize(t *testing.T) {
+	testCases := []struct {
+		name       string
+		method     string
+		handleFunc HandleFunc
+		wantSize   int
+	}{
+		{
+			name:   "buffered",
+			method: http.MethodGet,
+			handleFunc: func(ctx *Context) {
+				ctx.TEXT(http.StatusOK, "hello")
+			},
+			wantSize: 5,
+		},
+		{
+			name:   "no content",
+			method: http.MethodGet,
+			handleFunc: func(ctx *Context) {
+				ctx.SetStatusCode(http.StatusNoContent)
+				ctx.SetData([]byte("ignored"))
+			},
+		},
+		{
+			name:   "head",
+			method: http.MethodHead,
+			handleFunc: func(ctx *Context) {
+				ctx.TEXT(http.StatusOK, "hello")
+			},
+		},
+		{
+			name:   "stream",
+			method: http.MethodGet,
+			handleFunc: func(ctx *Context) {
+				ctx.SetData([]byte("start "))
+				ctx.Stream(func(w io.Writer) bool {
+					_, _ = io.WriteString(w, "data")
+					return false
+				})
+			},
+			wantSize: 10,
+		},
+		{
+			name:   "file",
+			method: http.MethodGet,
+			handleFunc: func(ctx *Context) {
+				ctx.FileFromFS("hello.txt", fstest.MapFS{"hello.txt": {Data: []byte("hello world")}})
+			},
+			wantSize: 11,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHTTP()
+			size := -1
+			h.Use(func(next HandleFunc) HandleFunc {
+				return func(ctx *Context) {
+					next(ctx)
+					// 缓冲的响应在这里还没有提交，流式响应已经写出去了
+					size = ctx.ResponseSize()
+				}
+			})
+			h.addRouter(tc.method, "/", tc.handleFunc)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(tc.method, "/", nil))
+			assert.Equal(t, tc.wantSize, size)
+			assert.Equal(t, tc.wantSize, recorder.Body.Len())
+		})
+	}
+}
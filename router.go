@@ -2,6 +2,7 @@ package bilibili_http
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -40,10 +41,10 @@ func newRouter() *router {
 // pattern = /
 // handleFunc = HandleFunc()
 // 意思是什么呢？就是说为 / 节点绑定一个视图函数
-func (r *router) addRouter(method string, pattern string, handleFunc HandleFunc, middlewareChains ...MiddlewareHandleFunc) {
+// 返回挂上视图函数的节点，方便调用方在上面组合中间件
+func (r *router) addRouter(method string, pattern string, handleFunc HandleFunc, middlewareChains ...MiddlewareHandleFunc) *node {
 	// method = GET
 	// pattern = /
-	fmt.Printf("add router %s - %s\n", method, pattern)
 	if pattern == "" {
 		panic("web: 路由不能为空")
 	}
@@ -61,7 +62,9 @@ func (r *router) addRouter(method string, pattern string, handleFunc HandleFunc,
 	// TODO 如果是根路由怎么办？
 	if pattern == "/" {
 		root.handleFunc = handleFunc
-		return
+		root.pattern = pattern
+		root.middlewareChains = middlewareChains
+		return root
 	}
 	if !strings.HasPrefix(pattern, "/") {
 		panic("web: 路由必须 / 开头")
@@ -86,8 +89,11 @@ func (r *router) addRouter(method string, pattern string, handleFunc HandleFunc,
 	}
 	// 设置视图函数
 	root.handleFunc = handleFunc
+	// 记录完整的路由，生成接口文档的时候需要用到
+	root.pattern = pattern
 	// 设置中间件列表
 	root.middlewareChains = middlewareChains
+	return root
 }
 
 // getRouter 匹配路由
@@ -99,27 +105,34 @@ func (r *router) addRouter(method string, pattern string, handleFunc HandleFunc,
 func (r *router) getRouter(method string, pattern string) (*node, map[string]string, bool) {
 	// 问题：为什么是一个kv都是string类型的
 	params := make(map[string]string)
+	n, ok := r.findRouter(method, pattern, params)
+	return n, params, ok
+}
+
+// findRouter 匹配路由，参数路由和通配符路由的参数写入params中
+// params 由调用方提供，这样上下文复用的时候参数的map也能一起复用
+func (r *router) findRouter(method string, pattern string, params map[string]string) (*node, bool) {
 	if pattern == "" {
-		return nil, params, false
+		return nil, false
 	}
 	// 获取根节点
 	root, ok := r.trees[method]
 	if !ok {
-		return nil, params, false
+		return nil, false
 	}
 	// TODO / 这种路由怎么办
 	if pattern == "/" {
-		return root, params, true
+		return root, true
 	}
 	// 切割pattern
 	parts := strings.Split(strings.Trim(pattern, "/"), "/")
-	for _, part := range parts {
+	for i, part := range parts {
 		if part == "" {
-			return nil, params, false
+			return nil, false
 		}
 		root = root.getNode(part)
 		if root == nil {
-			return nil, params, false
+			return nil, false
 		}
 		// 想一想：我们注册的路由是 /study/:course
 		// 					    /study/golang
@@ -139,17 +152,82 @@ func (r *router) getRouter(method string, pattern string) (*node, map[string]str
 		if strings.HasPrefix(root.part, "*") {
 			// /assets/*filepath
 			// /assets/css/index.css
-			index := strings.Index(pattern, part)
-			params[root.part[1:]] = pattern[index:]
+			// 这里不能用strings.Index去找part的位置
+			// /assets/assets/index.css 这种路径会找到第一个assets上
+			params[root.part[1:]] = strings.Join(parts[i:], "/")
 			// 直接return就表示后面的不在匹配节点了
-			return root, params, root.handleFunc != nil
+			return root, root.handleFunc != nil
+		}
+	}
+	return root, root.handleFunc != nil
+}
+
+// RouteInfo 已经注册的一条路由
+type RouteInfo struct {
+	Method  string
+	Pattern string
+	// handleFunc 生成接口文档的时候需要从Typed视图函数中取出参数和返回值的类型
+	handleFunc HandleFunc
+}
+
+// routes 按照路由、方法排序之后返回所有注册过的路由
+func (r *router) routes() []RouteInfo {
+	res := make([]RouteInfo, 0, 16)
+	for method, root := range r.trees {
+		res = root.collect(method, res)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Pattern != res[j].Pattern {
+			return res[i].Pattern < res[j].Pattern
 		}
+		return res[i].Method < res[j].Method
+	})
+	return res
+}
+
+// walk 遍历所有挂了视图函数的节点
+func (r *router) walk(fn func(n *node)) {
+	for _, root := range r.trees {
+		root.walk(fn)
+	}
+}
+
+func (n *node) walk(fn func(n *node)) {
+	if n.handleFunc != nil {
+		fn(n)
+	}
+	for _, child := range n.children {
+		child.walk(fn)
+	}
+	if n.paramChild != nil {
+		n.paramChild.walk(fn)
+	}
+	if n.starChild != nil {
+		n.starChild.walk(fn)
+	}
+}
+
+// collect 深度优先遍历路由树，收集挂了视图函数的节点
+func (n *node) collect(method string, res []RouteInfo) []RouteInfo {
+	if n.handleFunc != nil {
+		res = append(res, RouteInfo{Method: method, Pattern: n.pattern, handleFunc: n.handleFunc})
+	}
+	for _, child := range n.children {
+		res = child.collect(method, res)
+	}
+	if n.paramChild != nil {
+		res = n.paramChild.collect(method, res)
+	}
+	if n.starChild != nil {
+		res = n.starChild.collect(method, res)
 	}
-	return root, params, root.handleFunc != nil
+	return res
 }
 
 type node struct {
 	part string
+	// pattern 注册时完整的路由，只有挂了视图函数的节点才有
+	pattern string
 	// children 其实就是静态路由
 	children map[string]*node
 	// handleFunc 这里存的是当前节点上的视图函数
@@ -157,6 +235,11 @@ type node struct {
 	handleFunc HandleFunc
 	// 单一路由上的中间件列表
 	middlewareChains MiddlewareChains
+	// group 注册这个路由的路由组，路由组的中间件沿着它的parent收集
+	group *RouterGroup
+	// chain 全局、路由组以及路由上的中间件和视图函数预先组合好的调用链
+	// 注册路由或者调用Use的时候重新组合，处理请求的时候直接调用
+	chain HandleFunc
 
 	// paramChild 参数路由
 	// 问题一：为什么这里是一个纯的node节点呢？
@@ -39,8 +39,6 @@ func (r *RouterGroup) Group(prefix string) *RouterGroup {
 		engine: r.engine,
 		parent: r,
 	}
-	// 将新建的路由组添加到HTTPServer中
-	r.engine.groups = append(r.engine.groups, rg)
 	return rg
 }
 
@@ -48,36 +46,69 @@ func (r *RouterGroup) Group(prefix string) *RouterGroup {
 func (r *RouterGroup) Use(mids ...MiddlewareHandleFunc) {
 	// 问题：中间件放哪？维护在哪里？
 	r.middlewares = append(r.middlewares, mids...)
+	// 已经注册的路由也要用上新的中间件
+	r.engine.rebuildChains()
 }
 
 // 抽取出来的公共方法
+// 返回错误的视图函数通过 E 转换之后再注册：h.GET("/user", E(fn))
 
 // GET GET请求
 func (r *RouterGroup) GET(pattern string, handleFunc HandleFunc, middlewareChains ...MiddlewareHandleFunc) {
 	r.addRouter(http.MethodGet, pattern, handleFunc, middlewareChains...)
 }
 
-// POST GET请求
+// POST POST请求
 func (r *RouterGroup) POST(pattern string, handleFunc HandleFunc, middlewareChains ...MiddlewareHandleFunc) {
 	r.addRouter(http.MethodPost, pattern, handleFunc, middlewareChains...)
 }
 
-// DELETE GET请求
+// DELETE DELETE请求
 func (r *RouterGroup) DELETE(pattern string, handleFunc HandleFunc, middlewareChains ...MiddlewareHandleFunc) {
 	r.addRouter(http.MethodDelete, pattern, handleFunc, middlewareChains...)
 }
 
-// PUT GET请求
+// PUT PUT请求
 func (r *RouterGroup) PUT(pattern string, handleFunc HandleFunc, middlewareChains ...MiddlewareHandleFunc) {
 	r.addRouter(http.MethodPut, pattern, handleFunc, middlewareChains...)
 }
 
+// OPTIONS OPTIONS请求
+// 跨域的预检请求不需要注册，cors中间件会直接响应
+func (r *RouterGroup) OPTIONS(pattern string, handleFunc HandleFunc, middlewareChains ...MiddlewareHandleFunc) {
+	r.addRouter(http.MethodOptions, pattern, handleFunc, middlewareChains...)
+}
+
 // addRouter1 这里是注册路由的唯一路径
 // 这里是和router路由树直接交互的入口，所以必须调用router的addRouter方法
 func (r *RouterGroup) addRouter(method string, pattern string, handleFunc HandleFunc, middlewareChains ...MiddlewareHandleFunc) {
 	// 这里就是将路由组的唯一标识和需要注册的路由进行绑定
 	pattern = fmt.Sprintf("%s%s", r.prefix, pattern)
-	r.engine.router.addRouter(method, pattern, handleFunc, middlewareChains...)
+	// Typed视图函数的校验规则在注册的时候就检查，自定义的StructValidator有自己的规则
+	if sig, ok := signatureOf(handleFunc); ok && r.engine.validator == nil {
+		if err := checkValidateTags(sig.req); err != nil {
+			panic(err.Error())
+		}
+	}
+	n := r.engine.router.addRouter(method, pattern, handleFunc, middlewareChains...)
+	r.engine.logger.Debug("注册路由", F("method", method), F("pattern", pattern))
+	// 路由在注册的时候就和路由组绑定，不再根据请求路径去匹配路由组
+	n.group = r
+	r.engine.buildChain(n)
+}
+
+// chainMiddlewares 沿着parent往上收集所有路由组的中间件
+// 顺序是从外到内：最外层路由组的中间件最先执行，同一个路由组内按照Use的顺序执行
+func (r *RouterGroup) chainMiddlewares() []MiddlewareHandleFunc {
+	groups := make([]*RouterGroup, 0, 4)
+	for g := r; g != nil; g = g.parent {
+		groups = append(groups, g)
+	}
+	mids := make([]MiddlewareHandleFunc, 0, 8)
+	for i := len(groups) - 1; i >= 0; i-- {
+		mids = append(mids, groups[i].middlewares...)
+	}
+	return mids
 }
 
 func newRouterGroup() *RouterGroup {
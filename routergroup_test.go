@@ -0,0 +1,104 @@
+package bilibili_http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordMiddleware 记录中间件的执行顺序
+func recordMiddleware(logs *[]string, name string) MiddlewareHandleFunc {
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			*logs = append(*logs, name)
+			next(ctx)
+		}
+	}
+}
+
+// TestRouterGroupUse 测试先注册路由、后注册中间件的情况
+// 调用链是预先组合好的，Use之后需要重新组合
+func TestRouterGroupUse(t *testing.T) {
+	var logs []string
+	h := NewHTTP()
+	v1 := h.Group("/v1")
+	v1.GET("/user", func(ctx *Context) {
+		logs = append(logs, "handler")
+	}, recordMiddleware(&logs, "route"))
+	v1.Use(recordMiddleware(&logs, "v1"))
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/user", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, []string{"v1", "route", "handler"}, logs)
+}
+
+// TestRouterGroupMiddlewares 测试路由组中间件和路由的绑定关系
+func TestRouterGroupMiddlewares(t *testing.T) {
+	var logs []string
+	h := NewHTTP()
+	h.Use(recordMiddleware(&logs, "root"))
+	v1 := h.Group("/v1")
+	// 先创建内层路由组、先注册内层的中间件，执行顺序依然是从外到内
+	admin := v1.Group("/admin")
+	admin.Use(recordMiddleware(&logs, "admin"))
+	v1.Use(recordMiddleware(&logs, "v1-a"), recordMiddleware(&logs, "v1-b"))
+	v10 := h.Group("/v10")
+	v10.Use(recordMiddleware(&logs, "v10"))
+	// 前缀一样，但是是另外一个路由组
+	other := h.Group("/v1")
+
+	handler := func(ctx *Context) {
+		logs = append(logs, "handler")
+	}
+	v1.GET("/user", handler)
+	admin.GET("/user/:id", handler, recordMiddleware(&logs, "route"))
+	v10.GET("/user", handler)
+	other.GET("/order", handler)
+	h.GET("/v1/login", handler)
+
+	testCases := []struct {
+		name     string
+		path     string
+		wantLogs []string
+	}{
+		{
+			name:     "group",
+			path:     "/v1/user",
+			wantLogs: []string{"root", "v1-a", "v1-b", "handler"},
+		},
+		{
+			name:     "nested group",
+			path:     "/v1/admin/user/1",
+			wantLogs: []string{"root", "v1-a", "v1-b", "admin", "route", "handler"},
+		},
+		{
+			// /v1 的中间件不能作用到 /v10 上
+			name:     "segment boundary",
+			path:     "/v10/user",
+			wantLogs: []string{"root", "v10", "handler"},
+		},
+		{
+			name:     "same prefix other group",
+			path:     "/v1/order",
+			wantLogs: []string{"root", "handler"},
+		},
+		{
+			// 路径在 /v1 下面，但是是在根路由组上注册的
+			name:     "registered elsewhere",
+			path:     "/v1/login",
+			wantLogs: []string{"root", "handler"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs = nil
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, tc.wantLogs, logs)
+		})
+	}
+}
@@ -2,12 +2,11 @@ package bilibili_http
 
 import (
 	"context"
-	"fmt"
-	"log"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
-	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -15,6 +14,11 @@ import (
 // HandleFunc 视图函数签名
 type HandleFunc func(ctx *Context)
 
+// ErrorHandleFunc 返回错误的视图函数签名
+// 返回的错误统一交给HTTPServer上的ErrorHandler处理，视图函数中不需要再自己响应错误
+// 注册的时候通过 E 转换成HandleFunc
+type ErrorHandleFunc func(ctx *Context) error
+
 // MiddlewareChains 中间件责任链
 type MiddlewareChains []MiddlewareHandleFunc
 
@@ -51,8 +55,37 @@ type HTTPServer struct {
 	// 这里路由组其实是一个根路由组
 	*RouterGroup
 
-	// groups 维护整个项目所有的路由组
-	groups []*RouterGroup
+	// binders 用户注册的请求数据解析器，key是Content-Type
+	binders map[string]Binder
+	// strictBinding 内置解析器是否使用严格模式
+	strictBinding bool
+	// bodyMemoryLimit 请求体缓存在内存中的上限
+	bodyMemoryLimit int64
+	// maxBodySize 请求体的大小上限，0表示不限制
+	maxBodySize int64
+	// uploadConfig 文件上传相关的配置
+	uploadConfig UploadConfig
+	// templateEngine 模板引擎
+	templateEngine TemplateEngine
+	// cookieCodec 签名和加密Cookie使用的密钥
+	cookieCodec *cookieCodec
+	// errorHandler 统一处理视图函数返回的错误
+	errorHandler ErrorHandler
+	// validator 校验请求参数，为nil时使用默认的tag校验
+	validator StructValidator
+	// pool 复用上下文，减少每个请求的内存分配
+	pool sync.Pool
+
+	// defaultMiddlewares 内置的中间件，默认是flush和recovery，最先执行
+	defaultMiddlewares []MiddlewareHandleFunc
+	// globalMiddlewares 通过HTTPServer.Use注册的全局中间件，没有匹配到路由的请求也会执行
+	globalMiddlewares []MiddlewareHandleFunc
+	// notFound 没有匹配到路由时的调用链
+	notFound HandleFunc
+	// logger 框架内部使用的Logger
+	logger Logger
+	// trustedProxies 可信的代理，获取客户端IP的时候使用
+	trustedProxies []netip.Prefix
 }
 
 /*
@@ -70,23 +103,23 @@ func WithHTTPServerStop(fn func() error) HTTPOption {
 	return func(h *HTTPServer) {
 		if fn == nil {
 			fn = func() error {
-				fmt.Println("1231231312")
-				quit := make(chan os.Signal)
+				// signal.Notify不会阻塞地发送信号，所以channel必须有缓冲，否则可能错过信号
+				quit := make(chan os.Signal, 1)
 				signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-				<-quit
-				log.Println("Shutdown Server ...")
+				defer signal.Stop(quit)
+				sig := <-quit
+				h.logger.Info("开始关闭服务", F("signal", sig))
 
 				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 				defer cancel()
 				// 关闭之前：需要做某些操作
 				if err := h.srv.Shutdown(ctx); err != nil {
-					log.Fatal("Server Shutdown:", err)
+					// 超时之后还有没处理完的请求
+					h.logger.Error("关闭服务失败", F("error", err))
+					return err
 				}
 				// 关闭之后，需要做某些操作
-				select {
-				case <-ctx.Done():
-					log.Println("timeout of 5 seconds.")
-				}
+				h.logger.Info("服务已关闭")
 				return nil
 			}
 		}
@@ -98,98 +131,121 @@ func NewHTTP(opts ...HTTPOption) *HTTPServer {
 	// HTTPServer和RouterGroup相互嵌套的初始化是在这里实现的
 	rg := newRouterGroup()
 	h := &HTTPServer{
-		router:      newRouter(),
-		RouterGroup: rg,
+		router:             newRouter(),
+		RouterGroup:        rg,
+		errorHandler:       DefaultErrorHandler,
+		defaultMiddlewares: []MiddlewareHandleFunc{Flush(), Recovery(DefaultRecoveryHandler)},
+		logger:             defaultLogger(),
 	}
 	rg.engine = h
+	h.pool.New = func() any {
+		return &Context{
+			engine: h,
+			params: make(map[string]string),
+			header: http.Header{},
+		}
+	}
 	for _, opt := range opts {
 		opt(h)
 	}
+	h.buildNotFound()
 	return h
 }
 
+// putContext 释放上下文占用的资源之后放回池子中
+func (h *HTTPServer) putContext(c *Context) {
+	c.release()
+	h.pool.Put(c)
+}
+
+// Routes 返回所有注册过的路由，按照路由、方法排序
+func (h *HTTPServer) Routes() []RouteInfo {
+	return h.router.routes()
+}
+
 // ServeHTTP 接收请求，转发请求
 // 接收请求：接收前端传过来的请求
 // 转发请求：转发前端过来的请求到咱们的框架中
 // ServeHTTP方法向前对接前端请求，向后对接咱们的框架
 func (h *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	// 1. 匹配路由
-	n, params, ok := h.router.getRouter(r.Method, r.URL.Path)
+	// 1. 从池子中取出上下文，请求处理完之后放回去
+	if h.maxBodySize > 0 && r.Body != nil && r.Body != http.NoBody {
+		r.Body = newMaxBytesReader(w, r.Body, h.maxBodySize)
+	}
+	c := h.pool.Get().(*Context)
+	c.reset(w, r)
+	defer h.putContext(c)
+	// 2. 匹配路由
+	n, ok := h.router.findRouter(r.Method, r.URL.Path, c.params)
 	if !ok || n.handleFunc == nil {
-		w.WriteHeader(http.StatusNotFound)
-		_, _ = w.Write([]byte("404 NOT FOUND肯定失败"))
-		return
+		// 没有匹配到路由也要经过全局中间件
+		h.notFound(c)
+	} else {
+		c.route = n.pattern
+		if h.logger.Enabled(LevelDebug) {
+			c.Logger().Debug("处理请求")
+		}
+		// 3. 转发请求，调用链在注册路由的时候就已经组合好了
+		n.chain(c)
 	}
-	// 2. 构造当前请求的上下文
-	c := NewContext(w, r)
-	c.params = params
-	fmt.Printf("request %s - %s\n", c.Method, c.Pattern)
+	// 去掉了flush中间件的话，在这里把响应写回去
+	c.Commit()
+}
+
+// Use 注册全局中间件，在内置中间件之后、路由组中间件之前执行
+// 和路由组的Use不同，没有匹配到路由的请求也会执行全局中间件
+// 只想作用于注册过的路由的话，使用 h.RouterGroup.Use
+func (h *HTTPServer) Use(mids ...MiddlewareHandleFunc) {
+	h.globalMiddlewares = append(h.globalMiddlewares, mids...)
+	h.rebuildChains()
+}
+
+// buildChain 组合节点上完整的调用链：全局的中间件 -> 路由组的中间件 -> 路由的中间件 -> 视图函数
+func (h *HTTPServer) buildChain(n *node) {
 	// 将项目全局的中间件注册好
-	mids := []MiddlewareHandleFunc{flush(), recovery()}
-	// 搜集当前请求的所有中间件方法——路由组身上的中间件
-	// mids = append(mids, h.filterMiddlewares(c.Pattern)...)
-	gms := h.filterMiddlewares(c.Pattern)
-	if len(gms) != 0 {
-		mids = append(mids, gms...)
-	}
-	//if len(mids) == 0 {
-	//	// 若当前请求上没有配备任何中间件，就需要创建一个mids，用来维护所有的中间件
-	//	// 为什么？
-	//	//
-	//	mids = make([]MiddlewareHandleFunc, 0)
-	//}
-
-	// 将当前匹配大的视图节点中的中间件全部添加到mids切片中——当前视图身上的中间件
+	mids := h.globalChain()
+	// 搜集当前路由的所有中间件方法——注册路由的路由组以及它所有父级路由组身上的中间件
+	mids = append(mids, n.group.chainMiddlewares()...)
+	// 当前路由身上的中间件
 	mids = append(mids, n.middlewareChains...)
 
+	n.chain = compose(mids, n.handleFunc)
+}
+
+// globalChain 内置的中间件和全局中间件
+func (h *HTTPServer) globalChain() []MiddlewareHandleFunc {
+	mids := make([]MiddlewareHandleFunc, 0, len(h.defaultMiddlewares)+len(h.globalMiddlewares)+8)
+	mids = append(mids, h.defaultMiddlewares...)
+	return append(mids, h.globalMiddlewares...)
+}
+
+// buildNotFound 组合没有匹配到路由时的调用链
+func (h *HTTPServer) buildNotFound() {
+	h.notFound = compose(h.globalChain(), func(ctx *Context) {
+		ctx.SetStatusCode(http.StatusNotFound)
+		ctx.SetData([]byte("404 NOT FOUND肯定失败"))
+	})
+}
+
+// compose 把中间件和视图函数组合成一个调用链
+func compose(mids []MiddlewareHandleFunc, handleFunc HandleFunc) HandleFunc {
 	// 重头：如何构建出类似这样的代码？
-	handleFunc := n.handleFunc
 	for i := len(mids) - 1; i >= 0; i-- {
-		handleFunc = mids[i](handleFunc)
+		// 每一层的next都要判断请求有没有被中断
+		handleFunc = mids[i](abortable(handleFunc))
 	}
 	// 到这里之后，handleFunc其实就是mids[0]
-	// 2. 转发请求
-	handleFunc(c) // 这里是执行用户的视图函数
-	// c.flashDataToResponse() // 大功告成
+	return handleFunc
 }
 
-/*
-如果有中间件，咱们需要将匹配到的视图函数添加到中间件切片中
-如果没有，咱们也需要将匹配到的视图函数添加到中间件切片中
-*/
-
-// filterMiddlewares 匹配当前URL所对应的所有中间件
-func (h *HTTPServer) filterMiddlewares(pattern string) []MiddlewareHandleFunc {
-	// pattern /login
-	// 先明确，中间件放在哪里？
-	mids := make([]MiddlewareHandleFunc, 0)
-	for _, group := range h.groups {
-		if strings.HasPrefix(pattern, group.prefix) {
-			// strings.HasPrefix("asuihjdfocrenifgv", "") => True
-			// pattern = /v1/login
-			/*
-				[
-					{prefix: "", middlewares:[mid1, mid2]},
-					{prefix: "/v1", middlewares:[mid1, mid2]},
-					{prefix: "/v2", middlewares:[mid1, mid2]},
-				]
-			*/
-			mids = append(mids, group.middlewares...)
-		}
-	}
-	return mids
+// rebuildChains 中间件发生变化之后，重新组合所有路由的调用链
+// 只应该在服务启动之前调用，和处理请求并发执行是不安全的
+func (h *HTTPServer) rebuildChains() {
+	h.router.walk(h.buildChain)
+	h.buildNotFound()
 }
 
-/*
-先明确，中间件放在哪里？
-目前来说，中间件是放在每个路由组中
-我们现在是在HTTPServer身上，拿不到所有的路由组信息
-所以我们就像能不能在HTTPServer身上维护整个项目所有的路由组？
-又所有的路由组，就表示能够拿到整个项目中所有的中间件
-
-*/
-
 // Start 启动服务
 func (h *HTTPServer) Start(addr string) error {
 	h.srv = &http.Server{
@@ -7,7 +7,7 @@ import (
 	"time"
 )
 
-func Logger() MiddlewareHandleFunc {
+func timeLogger() MiddlewareHandleFunc {
 	return func(next HandleFunc) HandleFunc {
 		return func(ctx *Context) {
 			ctime := time.Now()
@@ -95,7 +95,7 @@ func TestHTTP_Start(t *testing.T) {
 		})
 	})
 	v1 := h.Group("/v1")
-	v1.Use(Logger())
+	v1.Use(timeLogger())
 	{
 		v1.GET("/login", func(ctx *Context) {
 			ctx.HTML(http.StatusOK, fmt.Sprintf(`<h1 style="color: red;">%s</h1>`, ctx.Pattern))
@@ -112,7 +112,7 @@ func TestHTTP_Start(t *testing.T) {
 		})
 	}
 	v3 := h.Group("/v3")
-	v3.Use(Logger())
+	v3.Use(timeLogger())
 	{
 		v3.GET("/login", func(ctx *Context) {
 			ctx.TEXT(http.StatusOK, fmt.Sprintf("请求成功：%s", ctx.Pattern))
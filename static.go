@@ -0,0 +1,250 @@
+package bilibili_http
+
+import (
+	"fmt"
+	"html"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StaticOption 静态文件服务的配置项
+type StaticOption func(s *staticHandler)
+
+// WithStaticIndex 访问目录时默认返回的文件，默认是index.html，传空字符串表示不返回
+func WithStaticIndex(index string) StaticOption {
+	return func(s *staticHandler) {
+		s.index = index
+	}
+}
+
+// WithStaticListing 目录下没有index文件时，是否列出目录中的文件
+func WithStaticListing(listing bool) StaticOption {
+	return func(s *staticHandler) {
+		s.listing = listing
+	}
+}
+
+// WithStaticSPA 单页应用模式：文件不存在时返回根目录下的index文件，交给前端路由处理
+func WithStaticSPA(spa bool) StaticOption {
+	return func(s *staticHandler) {
+		s.spa = spa
+	}
+}
+
+// WithStaticPrecompressed 客户端支持的情况下，优先返回预先压缩好的 .br 或者 .gz 文件
+// 例如请求 app.js，存在 app.js.br 并且 Accept-Encoding 包含 br，就直接返回 app.js.br
+func WithStaticPrecompressed(precompressed bool) StaticOption {
+	return func(s *staticHandler) {
+		s.precompressed = precompressed
+	}
+}
+
+// precompressedEncodings 预压缩文件的后缀，按照优先级排序
+var precompressedEncodings = []struct {
+	encoding  string
+	extension string
+}{
+	{encoding: "br", extension: ".br"},
+	{encoding: "gzip", extension: ".gz"},
+}
+
+// staticHandler 静态文件服务
+type staticHandler struct {
+	fsys          fs.FS
+	index         string
+	listing       bool
+	spa           bool
+	precompressed bool
+}
+
+// Static 将磁盘目录dir映射到prefix下
+// r.Static("/assets", "./public") 之后 /assets/css/index.css 对应 ./public/css/index.css
+func (r *RouterGroup) Static(prefix string, dir string, opts ...StaticOption) {
+	r.StaticFS(prefix, os.DirFS(dir), opts...)
+}
+
+// StaticFS 将文件系统fsys映射到prefix下，fsys可以是 embed.FS
+// 底层是注册了 prefix 和 prefix/*filepath 两个路由
+func (r *RouterGroup) StaticFS(prefix string, fsys fs.FS, opts ...StaticOption) {
+	s := &staticHandler{
+		fsys:  fsys,
+		index: "index.html",
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix = "/" + prefix
+	} else if r.prefix == "" {
+		// 挂载在根路由组的根路径上
+		prefix = "/"
+	}
+	pattern := strings.TrimSuffix(prefix, "/") + "/*filepath"
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		r.addRouter(method, prefix, s.handle)
+		r.addRouter(method, pattern, s.handle)
+	}
+}
+
+// StaticFile 将单个文件file映射到pattern上
+// r.StaticFile("/favicon.ico", "./public/favicon.ico")
+func (r *RouterGroup) StaticFile(pattern string, file string) {
+	s := &staticHandler{fsys: os.DirFS(filepath.Dir(file))}
+	name := filepath.Base(file)
+	handleFunc := func(ctx *Context) {
+		s.serve(ctx, name)
+	}
+	r.addRouter(http.MethodGet, pattern, handleFunc)
+	r.addRouter(http.MethodHead, pattern, handleFunc)
+}
+
+func (s *staticHandler) handle(ctx *Context) {
+	// 访问prefix本身的时候没有filepath参数
+	name, _ := ctx.Params("filepath")
+	// path.Clean 会把 .. 全部处理掉，保证不会访问到fsys以外的文件
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	if strings.Contains(name, "\\") || !fs.ValidPath(name) {
+		ctx.TEXT(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return
+	}
+	s.serve(ctx, name)
+}
+
+// serve 响应fsys中名为name的文件或者目录
+func (s *staticHandler) serve(ctx *Context, name string) {
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		if s.spa && s.index != "" && name != s.index {
+			s.serve(ctx, s.index)
+			return
+		}
+		ctx.TEXT(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return
+	}
+	if !info.IsDir() {
+		s.serveFile(ctx, name)
+		return
+	}
+	// 目录必须以 / 结尾，否则页面中的相对路径会出问题
+	// 和标准库一样使用相对路径重定向，//evil.com 这样的请求路径不会被当成其他的域名
+	if !strings.HasSuffix(ctx.Pattern, "/") {
+		location := path.Base(ctx.Pattern) + "/"
+		if query := ctx.request.URL.RawQuery; query != "" {
+			location += "?" + query
+		}
+		ctx.SetHeader("Location", location)
+		ctx.SetStatusCode(http.StatusMovedPermanently)
+		return
+	}
+	if s.index != "" {
+		index := path.Join(name, s.index)
+		if info, err = fs.Stat(s.fsys, index); err == nil && !info.IsDir() {
+			s.serveFile(ctx, index)
+			return
+		}
+	}
+	if s.listing {
+		s.serveDir(ctx, name)
+		return
+	}
+	ctx.TEXT(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+}
+
+// serveFile 响应文件内容，Range和条件请求由 Context.FileFromFS 处理
+func (s *staticHandler) serveFile(ctx *Context, name string) {
+	target, encoding := name, ""
+	if s.precompressed {
+		target, encoding = s.precompressedFile(ctx, name)
+		ctx.SetHeader("Vary", "Accept-Encoding")
+	}
+	// Content-Type 以原始文件为准，而不是压缩文件
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		ctx.SetHeader("Content-Type", contentType)
+	} else if encoding != "" {
+		ctx.SetHeader("Content-Type", "application/octet-stream")
+	}
+	if encoding != "" {
+		ctx.SetHeader("Content-Encoding", encoding)
+	}
+	ctx.FileFromFS(target, s.fsys)
+}
+
+// precompressedFile 根据Accept-Encoding选择预压缩的文件，没有合适的就返回原文件
+func (s *staticHandler) precompressedFile(ctx *Context, name string) (string, string) {
+	accepted := acceptedEncodings(ctx.request.Header.Get("Accept-Encoding"))
+	for _, pe := range precompressedEncodings {
+		if !accepted[pe.encoding] {
+			continue
+		}
+		if info, err := fs.Stat(s.fsys, name+pe.extension); err == nil && !info.IsDir() {
+			return name + pe.extension, pe.encoding
+		}
+	}
+	return name, ""
+}
+
+// acceptedEncodings 解析Accept-Encoding，q=0表示明确不接受
+func acceptedEncodings(header string) map[string]bool {
+	res := make(map[string]bool)
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ";")
+		encoding := strings.ToLower(strings.TrimSpace(parts[0]))
+		if encoding == "" {
+			continue
+		}
+		accepted := true
+		for _, param := range parts[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.TrimSpace(key) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q == 0 {
+				accepted = false
+			}
+		}
+		res[encoding] = accepted
+	}
+	return res
+}
+
+// serveDir 列出目录中的文件
+func (s *staticHandler) serveDir(ctx *Context, name string) {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		ctx.TEXT(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	var sb strings.Builder
+	title := html.EscapeString(ctx.Pattern)
+	sb.WriteString(fmt.Sprintf("<!doctype html>\n<title>%s</title>\n<h1>%s</h1>\n<ul>\n", title, title))
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		href := (&url.URL{Path: entryName}).String()
+		line := fmt.Sprintf("<li><a href=\"%s\">%s</a>", html.EscapeString(href), html.EscapeString(entryName))
+		if info, err := entry.Info(); err == nil && !entry.IsDir() {
+			line += fmt.Sprintf(" %d %s", info.Size(), info.ModTime().Format(time.RFC3339))
+		}
+		sb.WriteString(line + "</li>\n")
+	}
+	sb.WriteString("</ul>\n")
+	ctx.HTML(http.StatusOK, sb.String())
+}
@@ -0,0 +1,191 @@
+package bilibili_http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRouterGroupStaticFS 测试静态文件服务
+func TestRouterGroupStaticFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":        {Data: []byte("<h1>home</h1>")},
+		"css/index.css":     {Data: []byte("body{}")},
+		"js/app.js":         {Data: []byte("console.log(1)")},
+		"js/app.js.gz":      {Data: []byte("gzip")},
+		"js/app.js.br":      {Data: []byte("brotli")},
+		"assets/assets.txt": {Data: []byte("nested")},
+		"docs/a.txt":        {Data: []byte("a")},
+	}
+	testCases := []struct {
+		name   string
+		opts   []StaticOption
+		url    string
+		header map[string]string
+
+		wantCode     int
+		wantBody     string
+		wantHeader   map[string]string
+		wantLocation string
+	}{
+		{
+			name:       "file",
+			url:        "/static/css/index.css",
+			wantCode:   http.StatusOK,
+			wantBody:   "body{}",
+			wantHeader: map[string]string{"Content-Type": "text/css; charset=utf-8"},
+		},
+		{
+			name:     "same name as prefix",
+			url:      "/static/assets/assets.txt",
+			wantCode: http.StatusOK,
+			wantBody: "nested",
+		},
+		{
+			name:     "index",
+			url:      "/static/",
+			wantCode: http.StatusOK,
+			wantBody: "<h1>home</h1>",
+		},
+		{
+			name:         "directory redirect",
+			url:          "/static/docs",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "docs/",
+		},
+		{
+			name:     "directory without listing",
+			url:      "/static/docs/",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "directory listing",
+			opts:     []StaticOption{WithStaticListing(true)},
+			url:      "/static/docs/",
+			wantCode: http.StatusOK,
+			wantBody: "<!doctype html>\n<title>/static/docs/</title>\n<h1>/static/docs/</h1>\n<ul>\n<li><a href=\"a.txt\">a.txt</a> 1 0001-01-01T00:00:00Z</li>\n</ul>\n",
+		},
+		{
+			name:     "path traversal",
+			url:      "/static/../../go.mod",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "not found",
+			url:      "/static/missing.js",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "spa fallback",
+			opts:     []StaticOption{WithStaticSPA(true)},
+			url:      "/static/user/1",
+			wantCode: http.StatusOK,
+			wantBody: "<h1>home</h1>",
+		},
+		{
+			name:     "precompressed br",
+			opts:     []StaticOption{WithStaticPrecompressed(true)},
+			url:      "/static/js/app.js",
+			header:   map[string]string{"Accept-Encoding": "gzip, br"},
+			wantCode: http.StatusOK,
+			wantBody: "brotli",
+			wantHeader: map[string]string{
+				"Content-Encoding": "br",
+				"Content-Type":     "text/javascript; charset=utf-8",
+				"Vary":             "Accept-Encoding",
+			},
+		},
+		{
+			name:       "precompressed gzip",
+			opts:       []StaticOption{WithStaticPrecompressed(true)},
+			url:        "/static/js/app.js",
+			header:     map[string]string{"Accept-Encoding": "gzip, br;q=0"},
+			wantCode:   http.StatusOK,
+			wantBody:   "gzip",
+			wantHeader: map[string]string{"Content-Encoding": "gzip"},
+		},
+		{
+			name:       "precompressed identity",
+			opts:       []StaticOption{WithStaticPrecompressed(true)},
+			url:        "/static/js/app.js",
+			wantCode:   http.StatusOK,
+			wantBody:   "console.log(1)",
+			wantHeader: map[string]string{"Content-Encoding": ""},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHTTP()
+			h.StaticFS("/static", fsys, tc.opts...)
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			for key, value := range tc.header {
+				req.Header.Set(key, value)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, recorder.Body.String())
+			}
+			for key, value := range tc.wantHeader {
+				assert.Equal(t, value, recorder.Header().Get(key))
+			}
+			if tc.wantLocation != "" {
+				assert.Equal(t, tc.wantLocation, recorder.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+// TestRouterGroupStaticRoot 测试挂载在根路径上的静态文件服务
+func TestRouterGroupStaticRoot(t *testing.T) {
+	fsys := fstest.MapFS{
+		"docs/a.txt": {Data: []byte("a")},
+		"big.bin":    {Data: make([]byte, 1<<20)},
+	}
+	h := NewHTTP()
+	var committed bool
+	var buffered int
+	h.Use(func(next HandleFunc) HandleFunc {
+		return func(c *Context) {
+			next(c)
+			committed, buffered = c.Committed(), len(c.ResponseData())
+		}
+	})
+	h.StaticFS("/", fsys)
+
+	// //docs 不能重定向到 //docs/，浏览器会把它当成另外一个域名
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "//docs?a=1", nil))
+	assert.Equal(t, http.StatusMovedPermanently, recorder.Code)
+	assert.Equal(t, "docs/?a=1", recorder.Header().Get("Location"))
+
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/big.bin", nil))
+	// 文件直接写到了连接上，不会缓存在上下文中
+	assert.True(t, committed)
+	assert.Equal(t, 0, buffered)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 1<<20, recorder.Body.Len())
+	assert.Equal(t, "1048576", recorder.Header().Get("Content-Length"))
+}
+
+// TestRouterGroupStaticFile 测试单个文件的映射
+func TestRouterGroupStaticFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "robots.txt")
+	require.NoError(t, os.WriteFile(file, []byte("User-agent: *"), 0o600))
+	h := NewHTTP()
+	h.StaticFile("/robots.txt", file)
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/robots.txt", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "User-agent: *", recorder.Body.String())
+	assert.NotEmpty(t, recorder.Header().Get("Last-Modified"))
+}
@@ -0,0 +1,257 @@
+package bilibili_http
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TemplateEngine 模板引擎
+// 通过 WithTemplateEngine 注册到HTTPServer上，Context.Render 使用它渲染页面
+type TemplateEngine interface {
+	// Render 渲染名为name的模板
+	Render(w io.Writer, name string, data any) error
+}
+
+// WithTemplateEngine 设置模板引擎
+func WithTemplateEngine(engine TemplateEngine) HTTPOption {
+	return func(h *HTTPServer) {
+		h.templateEngine = engine
+	}
+}
+
+// HTMLTemplateEngine 基于 html/template 的模板引擎
+// 目录结构约定如下：
+//
+//	templates/
+//		layouts/base.tmpl     布局，通过 {{ block "content" . }}{{ end }} 预留位置
+//		partials/header.tmpl  公共片段，通过 {{ template "partials/header.tmpl" . }} 引用
+//		index.tmpl            页面，通过 {{ define "content" }}...{{ end }} 填充布局
+//
+// 每个页面单独组成一个模板集合：所有布局 + 所有公共片段 + 页面本身
+// 这样不同页面中同名的block互不影响
+type HTMLTemplateEngine struct {
+	fsys fs.FS
+	// layoutDir 布局所在的目录
+	layoutDir string
+	// partialDir 公共片段所在的目录
+	partialDir string
+	// extension 模板文件的后缀
+	extension string
+	// layout 默认使用的布局，为空表示直接渲染页面
+	layout string
+	// funcs 自定义的模板函数
+	funcs template.FuncMap
+	// reload 开发模式，文件发生变化的时候重新加载模板
+	reload bool
+
+	mutex     sync.RWMutex
+	templates map[string]*template.Template
+	// version 所有模板文件的修改时间和数量，用来判断文件有没有变化
+	version string
+}
+
+// TemplateOption HTMLTemplateEngine的配置项
+type TemplateOption func(e *HTMLTemplateEngine)
+
+// WithTemplateFuncs 注册自定义的模板函数
+func WithTemplateFuncs(funcs template.FuncMap) TemplateOption {
+	return func(e *HTMLTemplateEngine) {
+		for name, fn := range funcs {
+			e.funcs[name] = fn
+		}
+	}
+}
+
+// WithTemplateDirs 设置布局和公共片段所在的目录，默认是 layouts 和 partials
+func WithTemplateDirs(layoutDir, partialDir string) TemplateOption {
+	return func(e *HTMLTemplateEngine) {
+		e.layoutDir = strings.Trim(layoutDir, "/")
+		e.partialDir = strings.Trim(partialDir, "/")
+	}
+}
+
+// WithTemplateExtension 设置模板文件的后缀，默认是 .tmpl
+func WithTemplateExtension(ext string) TemplateOption {
+	return func(e *HTMLTemplateEngine) {
+		e.extension = ext
+	}
+}
+
+// WithTemplateLayout 设置默认的布局，例如 layouts/base.tmpl
+// 设置之后渲染页面时实际执行的是布局，页面只负责填充布局中的block
+func WithTemplateLayout(layout string) TemplateOption {
+	return func(e *HTMLTemplateEngine) {
+		e.layout = layout
+	}
+}
+
+// WithTemplateReload 开发模式，每次渲染前检查模板文件有没有变化，有变化就重新加载
+// embed.FS 中的文件不会变化，开启也没有意义
+func WithTemplateReload(reload bool) TemplateOption {
+	return func(e *HTMLTemplateEngine) {
+		e.reload = reload
+	}
+}
+
+// NewHTMLTemplateEngine 从fsys中加载模板，fsys可以是 embed.FS
+func NewHTMLTemplateEngine(fsys fs.FS, opts ...TemplateOption) (*HTMLTemplateEngine, error) {
+	e := &HTMLTemplateEngine{
+		fsys:       fsys,
+		layoutDir:  "layouts",
+		partialDir: "partials",
+		extension:  ".tmpl",
+		funcs:      template.FuncMap{},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// NewHTMLTemplateEngineFromDir 从磁盘目录中加载模板
+func NewHTMLTemplateEngineFromDir(dir string, opts ...TemplateOption) (*HTMLTemplateEngine, error) {
+	return NewHTMLTemplateEngine(os.DirFS(dir), opts...)
+}
+
+// Render 渲染名为name的页面
+func (e *HTMLTemplateEngine) Render(w io.Writer, name string, data any) error {
+	if e.reload {
+		if err := e.reloadIfChanged(); err != nil {
+			return err
+		}
+	}
+	e.mutex.RLock()
+	tpl, ok := e.templates[name]
+	e.mutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("web: 模板[%s]不存在", name)
+	}
+	if e.layout != "" {
+		return tpl.ExecuteTemplate(w, e.layout, data)
+	}
+	return tpl.Execute(w, data)
+}
+
+// scan 找出所有的模板文件，同时计算出当前的版本
+func (e *HTMLTemplateEngine) scan() (shared []string, pages []string, version string, err error) {
+	var latest time.Time
+	count := 0
+	err = fs.WalkDir(e.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(name) != e.extension {
+			return nil
+		}
+		if e.reload {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if info.ModTime().After(latest) {
+				latest = info.ModTime()
+			}
+		}
+		count++
+		if e.inDir(name, e.layoutDir) || e.inDir(name, e.partialDir) {
+			shared = append(shared, name)
+			return nil
+		}
+		pages = append(pages, name)
+		return nil
+	})
+	version = fmt.Sprintf("%d-%d", count, latest.UnixNano())
+	return
+}
+
+func (e *HTMLTemplateEngine) inDir(name string, dir string) bool {
+	return dir != "" && strings.HasPrefix(name, dir+"/")
+}
+
+// load 加载所有的模板
+func (e *HTMLTemplateEngine) load() error {
+	shared, pages, version, err := e.scan()
+	if err != nil {
+		return err
+	}
+	if len(pages) == 0 {
+		return errors.New("web: 没有找到任何模板")
+	}
+	sources := make(map[string]string, len(shared)+len(pages))
+	for _, name := range append(shared, pages...) {
+		content, err := fs.ReadFile(e.fsys, name)
+		if err != nil {
+			return err
+		}
+		sources[name] = string(content)
+	}
+	templates := make(map[string]*template.Template, len(pages))
+	for _, page := range pages {
+		tpl := template.New(page).Funcs(e.funcs)
+		// 先解析布局和公共片段，再解析页面，页面中的define才能覆盖布局中的block
+		for _, name := range shared {
+			if _, err = tpl.New(name).Parse(sources[name]); err != nil {
+				return err
+			}
+		}
+		if _, err = tpl.Parse(sources[page]); err != nil {
+			return err
+		}
+		templates[page] = tpl
+	}
+	e.mutex.Lock()
+	e.templates = templates
+	e.version = version
+	e.mutex.Unlock()
+	return nil
+}
+
+// reloadIfChanged 模板文件有变化的时候重新加载
+func (e *HTMLTemplateEngine) reloadIfChanged() error {
+	_, _, version, err := e.scan()
+	if err != nil {
+		return err
+	}
+	e.mutex.RLock()
+	changed := version != e.version
+	e.mutex.RUnlock()
+	if !changed {
+		return nil
+	}
+	return e.load()
+}
+
+// templateRender 使用模板引擎渲染页面
+type templateRender struct {
+	engine TemplateEngine
+	name   string
+	data   any
+}
+
+func (templateRender) ContentType() string {
+	return HTMLRender{}.ContentType()
+}
+
+func (r templateRender) Render(w io.Writer) error {
+	return r.engine.Render(w, r.name, r.data)
+}
+
+// Render 使用HTTPServer上配置的模板引擎渲染页面
+// ctx.Render(http.StatusOK, "index.tmpl", H{"title": "首页"})
+func (c *Context) Render(code int, name string, data any) {
+	if c.engine == nil || c.engine.templateEngine == nil {
+		panic("web: 没有配置模板引擎")
+	}
+	c.Respond(code, templateRender{engine: c.engine.templateEngine, name: name, data: data})
+}
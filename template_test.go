@@ -0,0 +1,85 @@
+package bilibili_http
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHTMLTemplateEngine 测试布局、公共片段以及自定义函数
+func TestHTMLTemplateEngine(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/base.tmpl":    {Data: []byte(`<html>{{ template "partials/title.tmpl" . }}{{ block "content" . }}default{{ end }}</html>`)},
+		"partials/title.tmpl":  {Data: []byte(`<title>{{ .Title | upper }}</title>`)},
+		"index.tmpl":           {Data: []byte(`{{ define "content" }}<h1>{{ .Name }}</h1>{{ end }}`)},
+		"users/list.tmpl":      {Data: []byte(`{{ define "content" }}{{ range .Users }}<li>{{ . }}</li>{{ end }}{{ end }}`)},
+		"users/empty.tmpl":     {Data: []byte(``)},
+		"static/ignored.html":  {Data: []byte(`{{ .Broken `)},
+		"partials/footer.tmpl": {Data: []byte(`footer`)},
+	}
+	engine, err := NewHTMLTemplateEngine(fsys,
+		WithTemplateLayout("layouts/base.tmpl"),
+		WithTemplateFuncs(template.FuncMap{"upper": strings.ToUpper}))
+	require.NoError(t, err)
+
+	h := NewHTTP(WithTemplateEngine(engine))
+	h.GET("/", func(ctx *Context) {
+		ctx.Render(http.StatusOK, "index.tmpl", H{"Title": "home", "Name": "<tom>"})
+	})
+	h.GET("/users", func(ctx *Context) {
+		ctx.Render(http.StatusOK, "users/list.tmpl", H{"Title": "users", "Users": []string{"a", "b"}})
+	})
+	h.GET("/empty", func(ctx *Context) {
+		ctx.Render(http.StatusOK, "users/empty.tmpl", H{"Title": "empty"})
+	})
+
+	testCases := []struct {
+		url      string
+		wantBody string
+	}{
+		{url: "/", wantBody: `<html><title>HOME</title><h1>&lt;tom&gt;</h1></html>`},
+		{url: "/users", wantBody: `<html><title>USERS</title><li>a</li><li>b</li></html>`},
+		{url: "/empty", wantBody: `<html><title>EMPTY</title>default</html>`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+// TestHTMLTemplateEngineReload 测试开发模式下模板文件变化之后自动重新加载
+func TestHTMLTemplateEngineReload(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "index.tmpl")
+	require.NoError(t, os.WriteFile(page, []byte(`v1 {{ . }}`), 0o600))
+
+	engine, err := NewHTMLTemplateEngineFromDir(dir, WithTemplateReload(true))
+	require.NoError(t, err)
+	var sb strings.Builder
+	require.NoError(t, engine.Render(&sb, "index.tmpl", "tom"))
+	assert.Equal(t, "v1 tom", sb.String())
+
+	require.NoError(t, os.WriteFile(page, []byte(`v2 {{ . }}`), 0o600))
+	// 确保修改时间发生了变化
+	future := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(page, future, future))
+	sb.Reset()
+	require.NoError(t, engine.Render(&sb, "index.tmpl", "tom"))
+	assert.Equal(t, "v2 tom", sb.String())
+
+	assert.Error(t, engine.Render(&sb, "missing.tmpl", nil))
+}
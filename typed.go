@@ -0,0 +1,110 @@
+package bilibili_http
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// Typed 把一个普通的函数包装成视图函数，参数的解析、校验以及响应的渲染都自动完成
+// 请求体按照Content-Type解析，查询参数按照 query tag 解析，路由参数按照 path tag 解析，
+// 查询参数和路由参数只会写入显式声明了对应tag的字段，
+// 后解析的会覆盖先解析的，所以路由参数的优先级最高。
+// 解析失败响应400，请求体过大响应413，校验失败响应422，Content-Type不支持响应415，fn返回的错误交给ErrorHandler处理。
+// 返回的Resp按照Accept协商成JSON、XML、YAML或者MessagePack，Resp为nil时响应204。
+//
+//	type GetUserReq struct {
+//		ID     int    `path:"id"`
+//		Fields string `query:"fields"`
+//	}
+//
+//	h.GET("/user/:id", Typed(func(ctx *Context, req *GetUserReq) (*User, error) {
+//		return userService.Get(ctx, req.ID)
+//	}))
+func Typed[Req any, Resp any](fn func(ctx *Context, req *Req) (*Resp, error)) HandleFunc {
+	handleFunc := func(ctx *Context) {
+		if ctx.signature != nil {
+			// 生成接口文档时的探测，只报告类型，不处理请求
+			ctx.signature.req = reflect.TypeOf((*Req)(nil)).Elem()
+			ctx.signature.resp = reflect.TypeOf((*Resp)(nil)).Elem()
+			return
+		}
+		req := new(Req)
+		if err := ctx.bindTyped(req); err != nil {
+			ctx.handleError(err)
+			return
+		}
+		resp, err := fn(ctx, req)
+		if err != nil {
+			ctx.handleError(err)
+			return
+		}
+		if resp == nil {
+			// fn中可能已经自己响应了，例如重定向
+			if !ctx.Written() {
+				ctx.SetStatusCode(http.StatusNoContent)
+			}
+			return
+		}
+		ctx.Negotiate(http.StatusOK, JSONRender{Data: resp}, XMLRender{Data: resp},
+			YAMLRender{Data: resp}, MsgpackRender{Data: resp})
+	}
+	typedCodes.Store(reflect.ValueOf(handleFunc).Pointer(), struct{}{})
+	return handleFunc
+}
+
+// typedCodes 所有Typed视图函数的代码地址
+// 同一个类型参数生成的闭包共享代码地址，只有在这里面的视图函数才能安全地用来探测类型
+// 其他的视图函数被调用的话会真的去处理请求
+var typedCodes sync.Map
+
+// typedSignature Typed视图函数的参数和返回值类型
+type typedSignature struct {
+	req  reflect.Type
+	resp reflect.Type
+}
+
+// signatureOf 获取Typed视图函数的参数和返回值类型，不是Typed视图函数返回false
+func signatureOf(handleFunc HandleFunc) (*typedSignature, bool) {
+	if _, ok := typedCodes.Load(reflect.ValueOf(handleFunc).Pointer()); !ok {
+		return nil, false
+	}
+	sig := &typedSignature{}
+	handleFunc(&Context{signature: sig})
+	return sig, sig.req != nil
+}
+
+// bindTyped 解析请求体、查询参数和路由参数，然后校验
+// 返回的错误都已经转换成了带状态码的 HTTPError
+func (c *Context) bindTyped(dest any) error {
+	if c.request.ContentLength != 0 {
+		if err := c.ShouldBind(dest); err != nil {
+			if errors.Is(err, ErrUnsupportedContentType) {
+				return NewHTTPError(http.StatusUnsupportedMediaType, err.Error()).WithCause(err)
+			}
+			return NewHTTPError(bindStatus(err), err.Error()).WithCause(err)
+		}
+	}
+	// 只有结构体才能从查询参数和路由参数中解析
+	if reflect.TypeOf(dest).Elem().Kind() == reflect.Struct {
+		if c.request.URL.RawQuery != "" {
+			if err := mapForm(dest, c.request.URL.Query(), nil, "query", false, true); err != nil {
+				return NewHTTPError(http.StatusBadRequest, err.Error()).WithCause(err)
+			}
+		}
+		if len(c.params) > 0 {
+			values := make(map[string][]string, len(c.params))
+			for key, value := range c.params {
+				values[key] = []string{value}
+			}
+			if err := mapForm(dest, values, nil, "path", false, true); err != nil {
+				return NewHTTPError(http.StatusBadRequest, err.Error()).WithCause(err)
+			}
+		}
+	}
+	if err := c.validate(dest); err != nil {
+		return NewHTTPError(http.StatusUnprocessableEntity, err.Error()).WithCause(err)
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package bilibili_http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type typedUserReq struct {
+	ID     int    `path:"id" json:"-"`
+	Fields string `query:"fields" json:"-"`
+	Name   string `json:"name" validate:"required,min=3,max=10"`
+	Role   string `json:"role" validate:"oneof=admin user"`
+}
+
+func (r *typedUserReq) Validate() error {
+	if r.Name == "admin" && r.Role != "admin" {
+		return errors.New("admin只能是管理员")
+	}
+	return nil
+}
+
+type typedUserResp struct {
+	ID     int    `json:"id" xml:"id"`
+	Name   string `json:"name" xml:"name"`
+	Fields string `json:"fields" xml:"fields"`
+}
+
+// TestTyped 测试泛型视图函数的解析、校验和渲染
+func TestTyped(t *testing.T) {
+	h := NewHTTP()
+	h.PUT("/user/:id", Typed(func(ctx *Context, req *typedUserReq) (*typedUserResp, error) {
+		if req.ID == 404 {
+			return nil, NewHTTPError(http.StatusNotFound, "用户不存在")
+		}
+		if req.ID == 204 {
+			return nil, nil
+		}
+		return &typedUserResp{ID: req.ID, Name: req.Name, Fields: req.Fields}, nil
+	}))
+	testCases := []struct {
+		name        string
+		url         string
+		body        string
+		contentType string
+		accept      string
+		wantCode    int
+		wantBody    string
+	}{
+		{
+			name:        "json",
+			url:         "/user/1?fields=name",
+			body:        `{"name":"tom","role":"user"}`,
+			contentType: MIMEJSON,
+			wantCode:    http.StatusOK,
+			wantBody:    `{"id":1,"name":"tom","fields":"name"}`,
+		},
+		{
+			name:        "xml",
+			url:         "/user/1",
+			body:        `{"name":"tom","role":"user"}`,
+			contentType: MIMEJSON,
+			accept:      MIMEXML,
+			wantCode:    http.StatusOK,
+			wantBody:    `<typedUserResp><id>1</id><name>tom</name><fields></fields></typedUserResp>`,
+		},
+		{
+			name:        "bad path param",
+			url:         "/user/abc",
+			body:        `{"name":"tom","role":"user"}`,
+			contentType: MIMEJSON,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "bad body",
+			url:         "/user/1",
+			body:        `{`,
+			contentType: MIMEJSON,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "unsupported content type",
+			url:         "/user/1",
+			body:        `name=tom`,
+			contentType: "application/x-unknown",
+			wantCode:    http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "tag validation",
+			url:         "/user/1",
+			body:        `{"name":"to","role":"root"}`,
+			contentType: MIMEJSON,
+			wantCode:    http.StatusUnprocessableEntity,
+			wantBody:    `{"code":422,"msg":"web: 字段[name]校验失败: min=3; web: 字段[role]校验失败: oneof=admin user"}`,
+		},
+		{
+			name:        "validate method",
+			url:         "/user/1",
+			body:        `{"name":"admin","role":"user"}`,
+			contentType: MIMEJSON,
+			wantCode:    http.StatusUnprocessableEntity,
+			wantBody:    `{"code":422,"msg":"admin只能是管理员"}`,
+		},
+		{
+			name:        "handler error",
+			url:         "/user/404",
+			body:        `{"name":"tom","role":"user"}`,
+			contentType: MIMEJSON,
+			wantCode:    http.StatusNotFound,
+			wantBody:    `{"code":404,"msg":"用户不存在"}`,
+		},
+		{
+			name:        "no content",
+			url:         "/user/204",
+			body:        `{"name":"tom","role":"user"}`,
+			contentType: MIMEJSON,
+			wantCode:    http.StatusNoContent,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tc.url, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, strings.TrimSpace(recorder.Body.String()))
+			}
+		})
+	}
+}
+
+type typedProfileReq struct {
+	Name  string `json:"name"`
+	Admin bool   `json:"admin"`
+}
+
+// TestTypedUntaggedFields 测试没有 query、path tag 的字段不会被查询参数和路由参数覆盖
+func TestTypedUntaggedFields(t *testing.T) {
+	h := NewHTTP()
+	handler := Typed(func(ctx *Context, req *typedProfileReq) (*typedProfileReq, error) {
+		return req, nil
+	})
+	h.POST("/user", handler)
+	h.POST("/user/:Name/:Admin", handler)
+	testCases := []struct {
+		name string
+		url  string
+	}{
+		{
+			name: "query",
+			url:  "/user?Admin=true&Name=evil&name=evil&admin=true",
+		},
+		{
+			name: "path",
+			url:  "/user/evil/true",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.url, strings.NewReader(`{"name":"tom"}`))
+			req.Header.Set("Content-Type", MIMEJSON)
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, `{"name":"tom","admin":false}`, strings.TrimSpace(recorder.Body.String()))
+		})
+	}
+}
+
+type typedBadRuleReq struct {
+	Name string `json:"name" validate:"required,email"`
+}
+
+type typedBadParamReq struct {
+	Age int `json:"age" validate:"min=abc"`
+}
+
+type typedNestedReq struct {
+	Next    *typedNestedReq   `json:"next"`
+	Profile *typedBadParamReq `json:"profile"`
+}
+
+type typedNode struct {
+	Name string     `json:"name" validate:"required"`
+	Next *typedNode `json:"next"`
+}
+
+// TestTypedValidateTags 测试注册Typed视图函数的时候检查校验规则
+func TestTypedValidateTags(t *testing.T) {
+	testCases := []struct {
+		name      string
+		opts      []HTTPOption
+		handler   HandleFunc
+		wantPanic string
+	}{
+		{
+			name: "unknown rule",
+			handler: Typed(func(ctx *Context, req *typedBadRuleReq) (*typedUserResp, error) {
+				return nil, nil
+			}),
+			wantPanic: "web: 字段[typedBadRuleReq.Name]不支持的校验规则 email",
+		},
+		{
+			name: "invalid param",
+			handler: Typed(func(ctx *Context, req *typedBadParamReq) (*typedUserResp, error) {
+				return nil, nil
+			}),
+			wantPanic: "web: 字段[typedBadParamReq.Age]非法的校验规则 min=abc",
+		},
+		{
+			// 嵌套的结构体也要检查，自己引用自己的结构体不会死循环
+			name: "nested",
+			handler: Typed(func(ctx *Context, req *typedNestedReq) (*typedUserResp, error) {
+				return nil, nil
+			}),
+			wantPanic: "web: 字段[typedBadParamReq.Age]非法的校验规则 min=abc",
+		},
+		{
+			name: "recursive",
+			handler: Typed(func(ctx *Context, req *typedNode) (*typedUserResp, error) {
+				return nil, nil
+			}),
+		},
+		{
+			// 自定义的StructValidator有自己的规则
+			name: "custom validator",
+			opts: []HTTPOption{WithValidator(noopValidator{})},
+			handler: Typed(func(ctx *Context, req *typedBadRuleReq) (*typedUserResp, error) {
+				return nil, nil
+			}),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHTTP(tc.opts...)
+			register := func() {
+				h.POST("/user", tc.handler)
+			}
+			if tc.wantPanic != "" {
+				assert.PanicsWithValue(t, tc.wantPanic, register)
+				return
+			}
+			assert.NotPanics(t, register)
+		})
+	}
+
+	// 校验失败只影响当前的请求，不会panic
+	h := NewHTTP()
+	h.POST("/node", Typed(func(ctx *Context, req *typedNode) (*typedUserResp, error) {
+		return nil, nil
+	}))
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/node", strings.NewReader(`{"name":"a","next":{"next":{}}}`))
+	req.Header.Set("Content-Type", MIMEJSON)
+	h.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	assert.Equal(t, `{"code":422,"msg":"web: 字段[name]校验失败: required; web: 字段[name]校验失败: required"}`, recorder.Body.String())
+}
+
+type noopValidator struct{}
+
+func (noopValidator) ValidateStruct(any) error {
+	return nil
+}
@@ -0,0 +1,333 @@
+package bilibili_http
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrFileTooLarge 单个文件超过了 UploadConfig.MaxFileSize
+	ErrFileTooLarge = errors.New("web: 上传的文件过大")
+	// ErrRequestTooLarge 请求体超过了 WithMaxBodySize 或者 UploadConfig.MaxTotalSize
+	ErrRequestTooLarge = errors.New("web: 请求体过大")
+	// ErrFileTypeNotAllowed 文件类型不在 UploadConfig.AllowedTypes 中
+	ErrFileTypeNotAllowed = errors.New("web: 不允许上传的文件类型")
+)
+
+// sniffLen 嗅探文件类型最多需要的字节数，和 http.DetectContentType 保持一致
+const sniffLen = 512
+
+// UploadConfig 文件上传相关的配置
+type UploadConfig struct {
+	// MaxMemory 解析multipart表单时最多放在内存中的字节数，超过的部分写入临时文件
+	MaxMemory int64
+	// MaxFileSize 单个文件的大小上限，0表示不限制
+	MaxFileSize int64
+	// MaxTotalSize 整个请求体的大小上限，0表示不限制
+	MaxTotalSize int64
+	// AllowedTypes 允许上传的文件类型，根据文件内容嗅探得到，为空表示不限制
+	// 支持 image/* 这种写法
+	AllowedTypes []string
+}
+
+// WithUploadConfig 设置文件上传相关的配置
+func WithUploadConfig(cfg UploadConfig) HTTPOption {
+	return func(h *HTTPServer) {
+		h.uploadConfig = cfg
+	}
+}
+
+// allowed 判断文件类型是否允许上传
+func (u UploadConfig) allowed(contentType string) bool {
+	if len(u.AllowedTypes) == 0 {
+		return true
+	}
+	mediaType := parseContentType(contentType)
+	for _, t := range u.AllowedTypes {
+		t = strings.ToLower(t)
+		if t == mediaType || t == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Context) uploadConfig() UploadConfig {
+	if c.engine == nil {
+		return UploadConfig{}
+	}
+	return c.engine.uploadConfig
+}
+
+// MultipartForm 解析multipart/form-data表单
+// 小文件放在内存中，大文件写入临时文件，请求结束之后临时文件会被自动删除
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	if c.multipartForm != nil {
+		return c.multipartForm, nil
+	}
+	cfg := c.uploadConfig()
+	reader, err := c.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	maxMemory := cfg.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = defaultMultipartMemory
+	}
+	if cfg.MaxFileSize > 0 {
+		// 在读取的过程中限制单个文件的大小，不能等到ReadForm把整个文件写到磁盘上之后才检查
+		pr, pw := io.Pipe()
+		defer pr.Close()
+		go limitParts(reader, pw, cfg.MaxFileSize)
+		reader = multipart.NewReader(pr, multipartBoundary)
+	}
+	form, err := reader.ReadForm(maxMemory)
+	if errors.Is(err, ErrFileTooLarge) {
+		return nil, ErrFileTooLarge
+	}
+	if errors.Is(err, ErrRequestTooLarge) {
+		return nil, ErrRequestTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = c.checkForm(form, cfg); err != nil {
+		_ = form.RemoveAll()
+		return nil, err
+	}
+	c.multipartForm = form
+	c.request.MultipartForm = form
+	return form, nil
+}
+
+// multipartBoundary limitParts 重新编码表单时使用的分隔符
+const multipartBoundary = "bilibili-http-multipart-boundary"
+
+// limitParts 把reader中的表单重新编码写到pw中，文件超过maxFileSize的时候立即中断
+// ReadForm 读到的错误就是 ErrFileTooLarge，已经写入的临时文件会被它自己清理掉
+func limitParts(reader *multipart.Reader, pw *io.PipeWriter, maxFileSize int64) {
+	mw := multipart.NewWriter(pw)
+	_ = mw.SetBoundary(multipartBoundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			pw.CloseWithError(mw.Close())
+			return
+		}
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		w, err := mw.CreatePart(part.Header)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		var src io.Reader = part
+		if part.FileName() != "" {
+			src = &limitedReader{r: part, n: maxFileSize, err: ErrFileTooLarge}
+		}
+		_, err = io.Copy(w, src)
+		_ = part.Close()
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+	}
+}
+
+// checkForm 校验表单中每个文件的大小和类型
+func (c *Context) checkForm(form *multipart.Form, cfg UploadConfig) error {
+	for _, fhs := range form.File {
+		for _, fh := range fhs {
+			if cfg.MaxFileSize > 0 && fh.Size > cfg.MaxFileSize {
+				return ErrFileTooLarge
+			}
+			if len(cfg.AllowedTypes) == 0 {
+				continue
+			}
+			contentType, err := FileContentType(fh)
+			if err != nil {
+				return err
+			}
+			if !cfg.allowed(contentType) {
+				return ErrFileTypeNotAllowed
+			}
+		}
+	}
+	return nil
+}
+
+// FormFile 获取表单中name对应的第一个文件
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	fhs := form.File[name]
+	if len(fhs) == 0 {
+		return nil, http.ErrMissingFile
+	}
+	return fhs[0], nil
+}
+
+// SaveUploadedFile 将上传的文件保存到dst，dst所在的目录不存在时会自动创建
+func (c *Context) SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err = os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, src)
+	return err
+}
+
+// FileContentType 根据文件开头的内容嗅探文件类型
+// 不相信客户端传过来的Content-Type
+func FileContentType(fh *multipart.FileHeader) (string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// MultipartReader 获取一个流式读取multipart表单的Reader
+// 如果请求体还没有被缓存，直接读取连接上的数据，之后就不能再通过RawBody获取请求体了
+// 整个请求体的大小受 UploadConfig.MaxTotalSize 限制
+func (c *Context) MultipartReader() (*multipart.Reader, error) {
+	mediaType, params, err := mime.ParseMediaType(c.request.Header.Get("Content-Type"))
+	if err != nil || (mediaType != MIMEMultipartPOSTForm && mediaType != "multipart/mixed") {
+		return nil, http.ErrNotMultipart
+	}
+	boundary, ok := params["boundary"]
+	if !ok {
+		return nil, http.ErrMissingBoundary
+	}
+	var body io.Reader = c.request.Body
+	if c.cacheBody != nil {
+		body = c.cacheBody.reader()
+	}
+	if body == nil {
+		return nil, errors.New("web: 请求体为空")
+	}
+	if limit := c.uploadConfig().MaxTotalSize; limit > 0 {
+		body = &limitedReader{r: body, n: limit, err: ErrRequestTooLarge}
+	}
+	return multipart.NewReader(body, boundary), nil
+}
+
+// UploadPart 流式读取时表单中的一个部分
+// 读取文件内容时受 UploadConfig.MaxFileSize 限制
+type UploadPart struct {
+	*multipart.Part
+	reader      io.Reader
+	contentType string
+}
+
+// Read 读取当前部分的内容
+func (p *UploadPart) Read(b []byte) (int, error) {
+	return p.reader.Read(b)
+}
+
+// IsFile 当前部分是不是一个文件
+func (p *UploadPart) IsFile() bool {
+	return p.FileName() != ""
+}
+
+// ContentType 根据内容嗅探得到的类型
+func (p *UploadPart) ContentType() string {
+	return p.contentType
+}
+
+// EachPart 流式遍历multipart表单中的每个部分
+// 数据不会被缓存到内存或者临时文件中，适合超大文件的上传，fn中直接把数据写到目的地即可
+// fn返回错误时停止遍历并返回该错误
+func (c *Context) EachPart(fn func(part *UploadPart) error) error {
+	reader, err := c.MultipartReader()
+	if err != nil {
+		return err
+	}
+	cfg := c.uploadConfig()
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		up := &UploadPart{Part: part, reader: part}
+		if up.IsFile() {
+			if cfg.MaxFileSize > 0 {
+				up.reader = &limitedReader{r: up.reader, n: cfg.MaxFileSize, err: ErrFileTooLarge}
+			}
+			br := bufio.NewReaderSize(up.reader, sniffLen)
+			head, err := br.Peek(sniffLen)
+			if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+				_ = part.Close()
+				return err
+			}
+			up.reader = br
+			up.contentType = http.DetectContentType(head)
+			if !cfg.allowed(up.contentType) {
+				_ = part.Close()
+				return ErrFileTypeNotAllowed
+			}
+		}
+		err = fn(up)
+		_ = part.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// limitedReader 和 io.LimitedReader 类似，只不过超过限制的时候返回指定的错误而不是 io.EOF
+type limitedReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// 已经读满了，再确认一下后面还有没有数据
+		var b [1]byte
+		n, err := l.r.Read(b[:])
+		if n > 0 {
+			return 0, l.err
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
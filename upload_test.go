@@ -0,0 +1,186 @@
+package bilibili_http
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A")
+
+// newUploadRequest 构造一个上传文件的请求
+func newUploadRequest(t *testing.T, files map[string][]byte) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	require.NoError(t, writer.WriteField("username", "tom"))
+	for name, data := range files {
+		fw, err := writer.CreateFormFile(name, name+".bin")
+		require.NoError(t, err)
+		_, err = fw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// TestContextFormFile 测试上传文件的大小和类型限制
+func TestContextFormFile(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  UploadConfig
+		data []byte
+
+		wantErr error
+	}{
+		{
+			name: "ok",
+			data: append(pngHeader, "image"...),
+		},
+		{
+			name:    "file too large",
+			cfg:     UploadConfig{MaxFileSize: 4},
+			data:    append(pngHeader, "image"...),
+			wantErr: ErrFileTooLarge,
+		},
+		{
+			name: "file at limit",
+			cfg:  UploadConfig{MaxFileSize: 13, MaxMemory: 1},
+			data: append(pngHeader, "image"...),
+		},
+		{
+			// 超过内存限制的文件会写到临时文件中，在写的过程中就要中断
+			name:    "file too large on disk",
+			cfg:     UploadConfig{MaxFileSize: 64, MaxMemory: 1},
+			data:    bytes.Repeat([]byte("a"), 128),
+			wantErr: ErrFileTooLarge,
+		},
+		{
+			name:    "request too large",
+			cfg:     UploadConfig{MaxTotalSize: 64},
+			data:    bytes.Repeat([]byte("a"), 128),
+			wantErr: ErrRequestTooLarge,
+		},
+		{
+			name: "allowed type",
+			cfg:  UploadConfig{AllowedTypes: []string{"image/*"}},
+			data: append(pngHeader, "image"...),
+		},
+		{
+			name:    "type not allowed",
+			cfg:     UploadConfig{AllowedTypes: []string{"image/*"}},
+			data:    []byte("plain text"),
+			wantErr: ErrFileTypeNotAllowed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := NewContext(httptest.NewRecorder(), newUploadRequest(t, map[string][]byte{"file": tc.data}))
+			ctx.engine = NewHTTP(WithUploadConfig(tc.cfg))
+			defer ctx.release()
+			fh, err := ctx.FormFile("file")
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(tc.data)), fh.Size)
+
+			dst := filepath.Join(t.TempDir(), "sub", fh.Filename)
+			require.NoError(t, ctx.SaveUploadedFile(fh, dst))
+			saved, err := os.ReadFile(dst)
+			require.NoError(t, err)
+			assert.Equal(t, tc.data, saved)
+		})
+	}
+}
+
+// TestContextBindMultipart 测试绑定multipart表单时上传配置的限制依然生效，并且不会缓存整个请求体
+func TestContextBindMultipart(t *testing.T) {
+	type form struct {
+		Username string `form:"username"`
+	}
+	testCases := []struct {
+		name string
+		cfg  UploadConfig
+		data []byte
+
+		wantErr  error
+		wantName string
+	}{
+		{
+			name:     "ok",
+			data:     []byte("hello"),
+			wantName: "tom",
+		},
+		{
+			name:    "request too large",
+			cfg:     UploadConfig{MaxTotalSize: 64},
+			data:    bytes.Repeat([]byte("a"), 1024),
+			wantErr: ErrRequestTooLarge,
+		},
+		{
+			name:    "file too large",
+			cfg:     UploadConfig{MaxFileSize: 16},
+			data:    bytes.Repeat([]byte("a"), 1024),
+			wantErr: ErrFileTooLarge,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := NewContext(httptest.NewRecorder(), newUploadRequest(t, map[string][]byte{"file": tc.data}))
+			ctx.engine = NewHTTP(WithUploadConfig(tc.cfg))
+			defer ctx.release()
+			var f form
+			err := ctx.ShouldBind(&f)
+			assert.Nil(t, ctx.cacheBody)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantName, f.Username)
+		})
+	}
+}
+
+// TestContextEachPart 测试流式读取上传的文件
+func TestContextEachPart(t *testing.T) {
+	data := append(pngHeader, bytes.Repeat([]byte("a"), 4096)...)
+	ctx := NewContext(httptest.NewRecorder(), newUploadRequest(t, map[string][]byte{"file": data}))
+	ctx.engine = NewHTTP(WithUploadConfig(UploadConfig{AllowedTypes: []string{"image/png"}}))
+
+	var fields, files int
+	err := ctx.EachPart(func(part *UploadPart) error {
+		if !part.IsFile() {
+			fields++
+			return nil
+		}
+		files++
+		assert.Equal(t, "image/png", part.ContentType())
+		got, err := io.ReadAll(part)
+		require.NoError(t, err)
+		assert.Equal(t, data, got)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, fields)
+	assert.Equal(t, 1, files)
+
+	ctx = NewContext(httptest.NewRecorder(), newUploadRequest(t, map[string][]byte{"file": data}))
+	ctx.engine = NewHTTP(WithUploadConfig(UploadConfig{MaxFileSize: 1024}))
+	err = ctx.EachPart(func(part *UploadPart) error {
+		_, err := io.Copy(io.Discard, part)
+		return err
+	})
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+}
@@ -0,0 +1,304 @@
+package bilibili_http
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Validator 请求参数自己实现校验逻辑，适合跨字段的校验
+//
+//	func (r *CreateUserReq) Validate() error {
+//		if r.Password != r.ConfirmPassword {
+//			return errors.New("两次密码不一致")
+//		}
+//		return nil
+//	}
+type Validator interface {
+	Validate() error
+}
+
+// StructValidator 根据结构体的tag校验参数
+// 默认的实现只支持几个常用的规则，需要更强大的校验可以接入第三方库，例如：
+// type playground struct{ v *validator.Validate }
+// func (p playground) ValidateStruct(obj any) error { return p.v.Struct(obj) }
+type StructValidator interface {
+	ValidateStruct(obj any) error
+}
+
+// WithValidator 设置校验参数使用的StructValidator
+func WithValidator(v StructValidator) HTTPOption {
+	return func(h *HTTPServer) {
+		h.validator = v
+	}
+}
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	// Field 字段名，优先使用json tag
+	Field string `json:"field"`
+	// Rule 没有通过的规则
+	Rule string `json:"rule"`
+	// Param 规则的参数，例如 min=3 中的 3
+	Param string `json:"param,omitempty"`
+}
+
+func (e FieldError) Error() string {
+	if e.Param == "" {
+		return fmt.Sprintf("web: 字段[%s]校验失败: %s", e.Field, e.Rule)
+	}
+	return fmt.Sprintf("web: 字段[%s]校验失败: %s=%s", e.Field, e.Rule, e.Param)
+}
+
+// ValidationErrors 所有没有通过校验的字段
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// tagValidator 默认的StructValidator，通过 validate tag 声明规则，多个规则用逗号分隔
+//
+//	type CreateUserReq struct {
+//		Name string `json:"name" validate:"required,min=3,max=20"`
+//		Role string `json:"role" validate:"oneof=admin user"`
+//	}
+//
+// 支持的规则：
+// required 不能是零值
+// min、max 数字比较大小，字符串、切片、map比较长度
+// oneof 只能是空格分隔的这几个值之一
+type tagValidator struct{}
+
+func (tagValidator) ValidateStruct(obj any) error {
+	rv := reflect.ValueOf(obj)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	if err := validateStruct(rv, &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateRule 解析好的一条校验规则
+type validateRule struct {
+	name  string
+	param string
+	// limit min、max 的参数
+	limit float64
+}
+
+// validateField 一个需要校验的字段
+type validateField struct {
+	index int
+	name  string
+	rules []validateRule
+	// nested 字段是结构体或者结构体指针，需要继续校验
+	nested bool
+}
+
+// validateFields 每个结构体解析好的规则，tag只解析一次，不用每个请求都解析
+var validateFields sync.Map
+
+// fieldsOf 获取结构体需要校验的字段，规则不合法的时候返回error
+func fieldsOf(rt reflect.Type) ([]validateField, error) {
+	if res, ok := validateFields.Load(rt); ok {
+		return res.([]validateField), nil
+	}
+	res := make([]validateField, 0, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		vf := validateField{index: i, name: fieldName(field), nested: nestedStruct(field.Type) != nil}
+		if rules, ok := field.Tag.Lookup("validate"); ok && rules != "-" {
+			for _, rule := range strings.Split(rules, ",") {
+				name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+				if name == "" {
+					continue
+				}
+				vr, err := parseRule(name, param)
+				if err != nil {
+					return nil, fmt.Errorf("web: 字段[%s.%s]%w", rt.Name(), field.Name, err)
+				}
+				vf.rules = append(vf.rules, vr)
+			}
+		}
+		if len(vf.rules) > 0 || vf.nested {
+			res = append(res, vf)
+		}
+	}
+	validateFields.Store(rt, res)
+	return res, nil
+}
+
+func parseRule(name string, param string) (validateRule, error) {
+	vr := validateRule{name: name, param: param}
+	switch name {
+	case "required", "oneof":
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return vr, fmt.Errorf("非法的校验规则 %s=%s", name, param)
+		}
+		vr.limit = limit
+	default:
+		return vr, fmt.Errorf("不支持的校验规则 %s", name)
+	}
+	return vr, nil
+}
+
+// nestedStruct 字段是结构体或者结构体指针的时候返回结构体类型，time.Time 不算
+func nestedStruct(rt reflect.Type) reflect.Type {
+	for rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	if rt.Kind() == reflect.Struct && rt != timeType {
+		return rt
+	}
+	return nil
+}
+
+// checkValidateTags 检查结构体以及嵌套的结构体中所有的校验规则，注册Typed视图函数的时候调用
+// 规则写错了在服务启动的时候就能发现，而不是每个请求都失败
+func checkValidateTags(rt reflect.Type) error {
+	return checkStructTags(nestedStruct(rt), map[reflect.Type]struct{}{})
+}
+
+func checkStructTags(rt reflect.Type, visited map[reflect.Type]struct{}) error {
+	if rt == nil {
+		return nil
+	}
+	// 自己引用自己的结构体，例如链表的节点
+	if _, ok := visited[rt]; ok {
+		return nil
+	}
+	visited[rt] = struct{}{}
+	fields, err := fieldsOf(rt)
+	if err != nil {
+		return err
+	}
+	for _, vf := range fields {
+		if vf.nested {
+			if err = checkStructTags(nestedStruct(rt.Field(vf.index).Type), visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateStruct(rv reflect.Value, errs *ValidationErrors) error {
+	fields, err := fieldsOf(rv.Type())
+	if err != nil {
+		return err
+	}
+	for _, vf := range fields {
+		fv := rv.Field(vf.index)
+		for _, rule := range vf.rules {
+			if !checkRule(fv, rule) {
+				*errs = append(*errs, FieldError{Field: vf.name, Rule: rule.name, Param: rule.param})
+				// 一个字段只报告第一个没有通过的规则
+				break
+			}
+		}
+		if !vf.nested {
+			continue
+		}
+		// 嵌套的结构体继续校验
+		for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			if err = validateStruct(fv, errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkRule(fv reflect.Value, rule validateRule) bool {
+	if rule.name == "required" {
+		return !fv.IsZero()
+	}
+	// 指针为nil时交给required判断
+	for fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return true
+		}
+		fv = fv.Elem()
+	}
+	if rule.name == "oneof" {
+		val := fmt.Sprint(fv.Interface())
+		for _, option := range strings.Fields(rule.param) {
+			if val == option {
+				return true
+			}
+		}
+		return false
+	}
+	var val float64
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		val = float64(fv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		val = float64(fv.Uint())
+	case reflect.Float32, reflect.Float64:
+		val = fv.Float()
+	case reflect.String:
+		val = float64(len([]rune(fv.String())))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		val = float64(fv.Len())
+	default:
+		return true
+	}
+	if rule.name == "min" {
+		return val >= rule.limit
+	}
+	return val <= rule.limit
+}
+
+// fieldName 错误中展示给客户端的字段名，和客户端传参时使用的名字保持一致
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "query", "path", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// validate 先按照tag校验，再调用参数自己的Validate方法
+func (c *Context) validate(obj any) error {
+	var v StructValidator = tagValidator{}
+	if c.engine != nil && c.engine.validator != nil {
+		v = c.engine.validator
+	}
+	if err := v.ValidateStruct(obj); err != nil {
+		return err
+	}
+	if val, ok := obj.(Validator); ok {
+		return val.Validate()
+	}
+	return nil
+}